@@ -1,8 +1,8 @@
 module HomeAccountingDB
 
-go 1.22
+go 1.23
 
-toolchain go1.22.1
+toolchain go1.23.0
 
 replace TimeSeriesData => ../TimeSeriesData
 
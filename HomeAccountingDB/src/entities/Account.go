@@ -2,7 +2,6 @@ package entities
 
 import (
 	"TimeSeriesData/core"
-	"encoding/binary"
 	"encoding/json"
 	"io"
 )
@@ -23,66 +22,45 @@ func (n *Int) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+//go:generate go run TimeSeriesData/cmd/binarygen -type Account
+
 type Account struct {
-	Id          int
-	Name        string
-	CashAccount Int `json:"isCash"`
-	ActiveTo    Date
-	Currency    string `json:"valutaCode"`
+	Id          int    `bin:"u32"`
+	Name        string `bin:"string"`
+	CashAccount Int    `json:"isCash" bin:"i32"`
+	ActiveTo    Date   `bin:"u32"`
+	Currency    string `json:"valutaCode" bin:"string"`
 }
 
 func (a Account) GetId() int {
 	return a.Id
 }
 
+func (a Account) FileKind() core.FileKind {
+	return AccountsFileKind
+}
+
+func (a Account) SchemaVersion() int {
+	return 2
+}
+
 func (a *Account) GetName() string {
 	return a.Name
 }
 
+// IsActive returns false when account was closed on or before the date
+func (a *Account) IsActive(date int) bool {
+	return a.ActiveTo <= 0 || int(a.ActiveTo) > date
+}
+
 func (a Account) Save(writer io.Writer) error {
-	err := binary.Write(writer, binary.LittleEndian, uint32(a.Id))
-	if err != nil {
-		return err
-	}
-	err = core.WriteStringToBinary(writer, a.Name)
-	if err != nil {
-		return err
-	}
-	err = binary.Write(writer, binary.LittleEndian, int32(a.CashAccount))
-	if err != nil {
-		return err
-	}
-	err = binary.Write(writer, binary.LittleEndian, uint32(a.ActiveTo))
-	if err != nil {
-		return err
-	}
-	return core.WriteStringToBinary(writer, a.Currency)
+	return a.EncodeBinary(writer)
 }
 
 func NewAccountFromBinary(reader io.Reader) (Account, error) {
-	var id uint32
-	err := binary.Read(reader, binary.LittleEndian, &id)
-	if err != nil {
-		return Account{}, nil
-	}
-	var name string
-	name, err = core.ReadStringFromBinary(reader)
-	if err != nil {
-		return Account{}, nil
-	}
-	var cashAccount int32
-	err = binary.Read(reader, binary.LittleEndian, &cashAccount)
-	if err != nil {
-		return Account{}, nil
-	}
-	var activeTo uint32
-	err = binary.Read(reader, binary.LittleEndian, &activeTo)
-	if err != nil {
-		return Account{}, nil
-	}
-	var currency string
-	currency, err = core.ReadStringFromBinary(reader)
-	return Account{Id: int(id), Name: name, CashAccount: Int(cashAccount), ActiveTo: Date(activeTo), Currency: currency}, err
+	var a Account
+	err := a.DecodeBinary(reader)
+	return a, err
 }
 
 func SaveAccountByIndex(index int, value any, writer io.Writer) error {
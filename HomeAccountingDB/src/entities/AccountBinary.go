@@ -0,0 +1,66 @@
+// Code generated by binarygen; DO NOT EDIT.
+
+package entities
+
+import (
+	"TimeSeriesData/core"
+	"io"
+)
+
+func (v Account) EncodeBinary(writer io.Writer) error {
+	if err := core.WriteInteger(writer, core.BinaryUint32, v.Id); err != nil {
+		return err
+	}
+	if err := core.WriteStringToBinary(writer, v.Name); err != nil {
+		return err
+	}
+	if err := core.WriteInteger(writer, core.BinaryInt32, v.CashAccount); err != nil {
+		return err
+	}
+	if err := core.WriteInteger(writer, core.BinaryUint32, v.ActiveTo); err != nil {
+		return err
+	}
+	if err := core.WriteStringToBinary(writer, v.Currency); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *Account) DecodeBinary(reader io.Reader) error {
+	{
+		x, err := core.ReadUint32(reader)
+		if err != nil {
+			return err
+		}
+		v.Id = int(x)
+	}
+	{
+		x, err := core.ReadStringFromBinary(reader)
+		if err != nil {
+			return err
+		}
+		v.Name = x
+	}
+	{
+		x, err := core.ReadInt32(reader)
+		if err != nil {
+			return err
+		}
+		v.CashAccount = Int(x)
+	}
+	{
+		x, err := core.ReadUint32(reader)
+		if err != nil {
+			return err
+		}
+		v.ActiveTo = Date(x)
+	}
+	{
+		x, err := core.ReadStringFromBinary(reader)
+		if err != nil {
+			return err
+		}
+		v.Currency = x
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package entities
 
 import (
+	"TimeSeriesData/core"
 	"bytes"
+	"math"
 	"reflect"
 	"testing"
 )
@@ -30,3 +32,32 @@ func TestAccountBinary(t *testing.T) {
 		t.Fatal("non zero length")
 	}
 }
+
+func TestAccountTruncatedBinary(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	err := Account{Id: 1, Name: "account1", Currency: "UAH"}.Save(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buffer.Bytes()
+	for l := 0; l < len(data); l++ {
+		_, err = NewAccountFromBinary(bytes.NewReader(data[:l]))
+		if err == nil {
+			t.Fatalf("error expected for %v bytes", l)
+		}
+	}
+}
+
+func TestAccountIdRange(t *testing.T) {
+	err := Account{Id: math.MaxUint32 + 1, Name: "account1", Currency: "UAH"}.Save(new(bytes.Buffer))
+	if err == nil {
+		t.Fatal("id overflow should be detected")
+	}
+}
+
+func FuzzAccountBinary(f *testing.F) {
+	fuzzBinary(f, func(data []byte) error {
+		_, err := core.LoadBinaryData(data, nil, NewAccountFromBinary)
+		return err
+	}, Account{Id: 1, Name: "account1", CashAccount: 5, ActiveTo: 10, Currency: "UAH"})
+}
@@ -19,6 +19,13 @@ func (n *Date) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// IsValid checks that date has yyyymmdd format
+func (n Date) IsValid() bool {
+	month := (n / 100) % 100
+	day := n % 100
+	return n > 10000000 && month >= 1 && month <= 12 && day >= 1 && day <= 31
+}
+
 func toInt(b []byte) int {
 	result := 0
 	minus := false
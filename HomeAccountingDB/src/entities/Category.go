@@ -2,36 +2,36 @@ package entities
 
 import (
 	"TimeSeriesData/core"
-	"encoding/binary"
 	"io"
 )
 
+//go:generate go run TimeSeriesData/cmd/binarygen -type Category
+
 type Category struct {
-	Id   int
-	Name string
+	Id   int    `bin:"u32"`
+	Name string `bin:"string"`
 }
 
 func (c Category) GetId() int {
 	return c.Id
 }
 
+func (c Category) FileKind() core.FileKind {
+	return CategoriesFileKind
+}
+
+func (c Category) SchemaVersion() int {
+	return 2
+}
+
 func (c Category) Save(writer io.Writer) error {
-	err := binary.Write(writer, binary.LittleEndian, uint32(c.Id))
-	if err != nil {
-		return err
-	}
-	return core.WriteStringToBinary(writer, c.Name)
+	return c.EncodeBinary(writer)
 }
 
 func NewCategoryFromBinary(reader io.Reader) (Category, error) {
-	var id uint32
-	err := binary.Read(reader, binary.LittleEndian, &id)
-	if err != nil {
-		return Category{}, nil
-	}
-	var name string
-	name, err = core.ReadStringFromBinary(reader)
-	return Category{int(id), name}, err
+	var c Category
+	err := c.DecodeBinary(reader)
+	return c, err
 }
 
 func SaveCategoryByIndex(index int, value any, writer io.Writer) error {
@@ -0,0 +1,36 @@
+// Code generated by binarygen; DO NOT EDIT.
+
+package entities
+
+import (
+	"TimeSeriesData/core"
+	"io"
+)
+
+func (v Category) EncodeBinary(writer io.Writer) error {
+	if err := core.WriteInteger(writer, core.BinaryUint32, v.Id); err != nil {
+		return err
+	}
+	if err := core.WriteStringToBinary(writer, v.Name); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *Category) DecodeBinary(reader io.Reader) error {
+	{
+		x, err := core.ReadUint32(reader)
+		if err != nil {
+			return err
+		}
+		v.Id = int(x)
+	}
+	{
+		x, err := core.ReadStringFromBinary(reader)
+		if err != nil {
+			return err
+		}
+		v.Name = x
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package entities
 
 import (
+	"TimeSeriesData/core"
 	"bytes"
 	"reflect"
 	"testing"
@@ -27,3 +28,10 @@ func TestCategoryBinary(t *testing.T) {
 		t.Fatal("non zero length")
 	}
 }
+
+func FuzzCategoryBinary(f *testing.F) {
+	fuzzBinary(f, func(data []byte) error {
+		_, err := core.LoadBinaryData(data, nil, NewCategoryFromBinary)
+		return err
+	}, Category{Id: 1, Name: "category1"})
+}
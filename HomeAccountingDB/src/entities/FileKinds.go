@@ -0,0 +1,12 @@
+package entities
+
+import "TimeSeriesData/core"
+
+// kinds of binary files
+const (
+	AccountsFileKind core.FileKind = iota + 1
+	CategoriesFileKind
+	SubcategoriesFileKind
+	HintsFileKind
+	FinanceRecordFileKind
+)
@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math"
 )
 
 type FinOpPropertyCode int
@@ -40,6 +39,44 @@ func FinOpPropertyCodeFromString(v string) (FinOpPropertyCode, error) {
 	}
 }
 
+func (n FinOpPropertyCode) String() string {
+	switch n {
+	case Amou:
+		return "AMOU"
+	case Dist:
+		return "DIST"
+	case Netw:
+		return "NETW"
+	case Ppto:
+		return "PPTO"
+	case Seca:
+		return "SECA"
+	case Typ:
+		return "TYPE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+type FinOpPropertyValueType int
+
+const (
+	NumericProperty FinOpPropertyValueType = 0
+	StringProperty  FinOpPropertyValueType = iota
+	DateProperty    FinOpPropertyValueType = iota
+)
+
+func (n FinOpPropertyCode) GetValueType() FinOpPropertyValueType {
+	switch n {
+	case Netw, Typ:
+		return StringProperty
+	case Ppto:
+		return DateProperty
+	default:
+		return NumericProperty
+	}
+}
+
 func (n *FinOpPropertyCode) UnmarshalJSON(b []byte) error {
 	var v string
 	err := json.Unmarshal(b, &v)
@@ -50,20 +87,35 @@ func (n *FinOpPropertyCode) UnmarshalJSON(b []byte) error {
 	return err
 }
 
+//go:generate go run TimeSeriesData/cmd/binarygen -type FinOpProperty,FinanceOperation
+
 type FinOpProperty struct {
-	NumericValue *int
-	StringValue  *string
-	DateValue    Date
-	PropertyCode FinOpPropertyCode
+	NumericValue *int              `bin:"i64,nil=max"`
+	StringValue  *string           `bin:"string,nil=empty"`
+	DateValue    Date              `bin:"u32"`
+	PropertyCode FinOpPropertyCode `bin:"u8"`
+}
+
+// HasValidValue checks that property has only one value of the type required by property code
+func (prop *FinOpProperty) HasValidValue() bool {
+	switch prop.PropertyCode.GetValueType() {
+	case StringProperty:
+		return prop.StringValue != nil && len(*prop.StringValue) > 0 && prop.NumericValue == nil && prop.DateValue == 0
+	case DateProperty:
+		return prop.DateValue.IsValid() && prop.NumericValue == nil && prop.StringValue == nil
+	default:
+		return prop.NumericValue != nil && prop.StringValue == nil && prop.DateValue == 0
+	}
 }
 
 type FinanceOperation struct {
-	Date            int `json:"-"`
-	Amount          *Decimal
-	Summa           Decimal
-	SubcategoryId   int
-	FinOpProperties []FinOpProperty `json:"finOpProperies"`
-	AccountId       int
+	Date            int             `json:"-" bin:"u32"`
+	Id              int             `json:"-" bin:"u32"`
+	AccountId       int             `bin:"u32"`
+	SubcategoryId   int             `bin:"u32"`
+	Summa           Decimal         `bin:"i64"`
+	Amount          *Decimal        `bin:"i64,nil=max"`
+	FinOpProperties []FinOpProperty `json:"finOpProperies" bin:"[]struct"`
 }
 
 type FinanceChange struct {
@@ -96,6 +148,33 @@ func (c *FinanceChange) SaveToBinary(writer io.Writer) error {
 	return binary.Write(writer, binary.LittleEndian, int64(c.SummaExpenditure))
 }
 
+func (op *FinanceOperation) GetProperty(code FinOpPropertyCode) *FinOpProperty {
+	for i := range op.FinOpProperties {
+		if op.FinOpProperties[i].PropertyCode == code {
+			return &op.FinOpProperties[i]
+		}
+	}
+	return nil
+}
+
+// estimateSize returns rough estimate of memory used by the operation in bytes
+func (op *FinanceOperation) estimateSize() int {
+	size := 72
+	if op.Amount != nil {
+		size += 8
+	}
+	for _, prop := range op.FinOpProperties {
+		size += 32
+		if prop.NumericValue != nil {
+			size += 8
+		}
+		if prop.StringValue != nil {
+			size += 16 + len(*prop.StringValue)
+		}
+	}
+	return size
+}
+
 func (op *FinanceOperation) UpdateChanges(changes map[int]*FinanceChange, accounts core.DictionaryData[Account],
 	subcategories core.DictionaryData[Subcategory]) error {
 	subcategory, err := subcategories.Get(op.SubcategoryId)
@@ -205,141 +284,35 @@ func (op *FinanceOperation) handleTRFR(current *FinanceChange, changes map[int]*
 	return nil
 }
 
-func (prop *FinOpProperty) SaveToBinary(writer io.Writer) error {
-	var err error
-	if prop.NumericValue != nil {
-		err = binary.Write(writer, binary.LittleEndian, int64(*prop.NumericValue))
-	} else {
-		err = binary.Write(writer, binary.LittleEndian, int64(math.MaxInt64))
-	}
-	if err != nil {
-		return err
-	}
-	if prop.StringValue != nil {
-		err = core.WriteStringToBinary(writer, *prop.StringValue)
-	} else {
-		err = core.WriteStringToBinary(writer, "")
-	}
-	if err != nil {
-		return err
-	}
-	v := uint32(prop.DateValue)
-	err = binary.Write(writer, binary.LittleEndian, v)
-	if err != nil {
-		return err
-	}
-	c := uint8(prop.PropertyCode)
-	return binary.Write(writer, binary.LittleEndian, c)
-}
-
-func (op *FinanceOperation) SaveToBinary(writer io.Writer) error {
-	err := binary.Write(writer, binary.LittleEndian, uint32(op.Date))
-	if err != nil {
-		return err
-	}
-	err = binary.Write(writer, binary.LittleEndian, uint32(op.AccountId))
-	if err != nil {
-		return err
-	}
-	err = binary.Write(writer, binary.LittleEndian, uint32(op.SubcategoryId))
-	if err != nil {
-		return err
-	}
-	err = binary.Write(writer, binary.LittleEndian, int64(op.Summa))
-	if err != nil {
-		return err
-	}
-	var a int64 = math.MaxInt64
-	if op.Amount != nil {
-		a = int64(*op.Amount)
-	}
-	err = binary.Write(writer, binary.LittleEndian, a)
-	if err != nil {
-		return err
-	}
-	err = binary.Write(writer, binary.LittleEndian, uint16(len(op.FinOpProperties)))
-	if err != nil {
-		return err
-	}
-	for _, prop := range op.FinOpProperties {
-		err = prop.SaveToBinary(writer)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 func NewFinOpPropertyFromBinary(reader io.Reader) (FinOpProperty, error) {
 	var prop FinOpProperty
-	var v64 int64
-	err := binary.Read(reader, binary.LittleEndian, &v64)
-	if v64 != math.MaxInt64 {
-		v := int(v64)
-		prop.NumericValue = &v
-	}
-	var s string
-	s, err = core.ReadStringFromBinary(reader)
-	if len(s) != 0 {
-		prop.StringValue = &s
-	}
-	var d uint32
-	err = binary.Read(reader, binary.LittleEndian, &d)
-	if err != nil {
-		return prop, err
-	}
-	prop.DateValue = Date(d)
-	var c uint8
-	err = binary.Read(reader, binary.LittleEndian, &c)
-	if err != nil {
-		return prop, err
-	}
-	prop.PropertyCode = FinOpPropertyCode(c)
-	return prop, nil
+	err := prop.DecodeBinary(reader)
+	return prop, err
 }
 
 func NewFinanceOperationFromBinary(reader io.Reader) (FinanceOperation, error) {
 	var op FinanceOperation
-	var v uint32
-	var v64 int64
-	err := binary.Read(reader, binary.LittleEndian, &v)
-	if err != nil {
-		return op, err
-	}
-	op.Date = int(v)
-	err = binary.Read(reader, binary.LittleEndian, &v)
-	if err != nil {
-		return op, err
-	}
-	op.AccountId = int(v)
-	err = binary.Read(reader, binary.LittleEndian, &v)
-	if err != nil {
-		return op, err
-	}
-	op.SubcategoryId = int(v)
-	err = binary.Read(reader, binary.LittleEndian, &v64)
-	if err != nil {
-		return op, err
-	}
-	op.Summa = Decimal(v64)
-	err = binary.Read(reader, binary.LittleEndian, &v64)
-	if err != nil {
-		return op, err
-	}
-	if v64 != math.MaxInt64 {
-		var d = Decimal(v64)
-		op.Amount = &d
-	}
-	var ll uint16
-	err = binary.Read(reader, binary.LittleEndian, &ll)
+	err := op.DecodeBinary(reader)
+	return op, err
+}
+
+// legacyFinanceOperation is the layout of operations in files written before operation ids and the file header
+// were introduced
+type legacyFinanceOperation struct {
+	Date            int             `bin:"u32"`
+	AccountId       int             `bin:"u32"`
+	SubcategoryId   int             `bin:"u32"`
+	Summa           Decimal         `bin:"i64"`
+	Amount          *Decimal        `bin:"i64,nil=max"`
+	FinOpProperties []FinOpProperty `bin:"[]struct"`
+}
+
+func newLegacyFinanceOperationFromBinary(reader io.Reader, id int) (FinanceOperation, error) {
+	var op legacyFinanceOperation
+	err := core.Decode(reader, &op)
 	if err != nil {
-		return op, err
-	}
-	for ll > 0 {
-		var prop FinOpProperty
-		prop, err = NewFinOpPropertyFromBinary(reader)
-		op.FinOpProperties = append(op.FinOpProperties, prop)
-		ll--
+		return FinanceOperation{}, err
 	}
-	return op, nil
+	return FinanceOperation{Date: op.Date, Id: id, AccountId: op.AccountId, SubcategoryId: op.SubcategoryId,
+		Summa: op.Summa, Amount: op.Amount, FinOpProperties: op.FinOpProperties}, nil
 }
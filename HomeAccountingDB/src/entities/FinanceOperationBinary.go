@@ -0,0 +1,177 @@
+// Code generated by binarygen; DO NOT EDIT.
+
+package entities
+
+import (
+	"TimeSeriesData/core"
+	"io"
+	"math"
+)
+
+func (v FinOpProperty) EncodeBinary(writer io.Writer) error {
+	if v.NumericValue != nil {
+		if err := core.WriteInteger(writer, core.BinaryInt64, *v.NumericValue); err != nil {
+			return err
+		}
+	} else {
+		if err := core.WriteInt64(writer, math.MaxInt64); err != nil {
+			return err
+		}
+	}
+	if v.StringValue != nil {
+		if err := core.WriteStringToBinary(writer, *v.StringValue); err != nil {
+			return err
+		}
+	} else {
+		if err := core.WriteStringToBinary(writer, ""); err != nil {
+			return err
+		}
+	}
+	if err := core.WriteInteger(writer, core.BinaryUint32, v.DateValue); err != nil {
+		return err
+	}
+	if err := core.WriteInteger(writer, core.BinaryUint8, v.PropertyCode); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *FinOpProperty) DecodeBinary(reader io.Reader) error {
+	{
+		x, err := core.ReadInt64(reader)
+		if err != nil {
+			return err
+		}
+		v.NumericValue = nil
+		if x != math.MaxInt64 {
+			value := int(x)
+			v.NumericValue = &value
+		}
+	}
+	{
+		x, err := core.ReadStringFromBinary(reader)
+		if err != nil {
+			return err
+		}
+		v.StringValue = nil
+		if len(x) != 0 {
+			value := x
+			v.StringValue = &value
+		}
+	}
+	{
+		x, err := core.ReadUint32(reader)
+		if err != nil {
+			return err
+		}
+		v.DateValue = Date(x)
+	}
+	{
+		x, err := core.ReadUint8(reader)
+		if err != nil {
+			return err
+		}
+		v.PropertyCode = FinOpPropertyCode(x)
+	}
+	return nil
+}
+
+func (v FinanceOperation) EncodeBinary(writer io.Writer) error {
+	if err := core.WriteInteger(writer, core.BinaryUint32, v.Date); err != nil {
+		return err
+	}
+	if err := core.WriteInteger(writer, core.BinaryUint32, v.Id); err != nil {
+		return err
+	}
+	if err := core.WriteInteger(writer, core.BinaryUint32, v.AccountId); err != nil {
+		return err
+	}
+	if err := core.WriteInteger(writer, core.BinaryUint32, v.SubcategoryId); err != nil {
+		return err
+	}
+	if err := core.WriteInteger(writer, core.BinaryInt64, v.Summa); err != nil {
+		return err
+	}
+	if v.Amount != nil {
+		if err := core.WriteInteger(writer, core.BinaryInt64, *v.Amount); err != nil {
+			return err
+		}
+	} else {
+		if err := core.WriteInt64(writer, math.MaxInt64); err != nil {
+			return err
+		}
+	}
+	if err := core.WriteLength(writer, len(v.FinOpProperties)); err != nil {
+		return err
+	}
+	for _, e := range v.FinOpProperties {
+		if err := core.Encode(writer, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *FinanceOperation) DecodeBinary(reader io.Reader) error {
+	{
+		x, err := core.ReadUint32(reader)
+		if err != nil {
+			return err
+		}
+		v.Date = int(x)
+	}
+	{
+		x, err := core.ReadUint32(reader)
+		if err != nil {
+			return err
+		}
+		v.Id = int(x)
+	}
+	{
+		x, err := core.ReadUint32(reader)
+		if err != nil {
+			return err
+		}
+		v.AccountId = int(x)
+	}
+	{
+		x, err := core.ReadUint32(reader)
+		if err != nil {
+			return err
+		}
+		v.SubcategoryId = int(x)
+	}
+	{
+		x, err := core.ReadInt64(reader)
+		if err != nil {
+			return err
+		}
+		v.Summa = Decimal(x)
+	}
+	{
+		x, err := core.ReadInt64(reader)
+		if err != nil {
+			return err
+		}
+		v.Amount = nil
+		if x != math.MaxInt64 {
+			value := Decimal(x)
+			v.Amount = &value
+		}
+	}
+	{
+		n, err := core.ReadArrayLength(reader)
+		if err != nil {
+			return err
+		}
+		v.FinOpProperties = nil
+		for ; n > 0; n-- {
+			var e FinOpProperty
+			if err := core.Decode(reader, &e); err != nil {
+				return err
+			}
+			v.FinOpProperties = append(v.FinOpProperties, e)
+		}
+	}
+	return nil
+}
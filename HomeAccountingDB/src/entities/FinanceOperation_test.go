@@ -0,0 +1,104 @@
+package entities
+
+import (
+	"TimeSeriesData/core"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type reflectedFinanceOperation FinanceOperation
+
+func TestFinanceOperationBinaryLayout(t *testing.T) {
+	n := -7
+	op := FinanceOperation{Date: 20200101, Id: 2, AccountId: 3, SubcategoryId: 4, Summa: -5,
+		FinOpProperties: []FinOpProperty{{NumericValue: &n, DateValue: 20200102, PropertyCode: Dist}}}
+	buffer := new(bytes.Buffer)
+	err := op.EncodeBinary(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// layout written by the hand-coded serialization
+	expected := binary.LittleEndian.AppendUint32(nil, 20200101)
+	expected = binary.LittleEndian.AppendUint32(expected, 2)
+	expected = binary.LittleEndian.AppendUint32(expected, 3)
+	expected = binary.LittleEndian.AppendUint32(expected, 4)
+	expected = binary.LittleEndian.AppendUint64(expected, uint64(math.MaxUint64-4))
+	expected = binary.LittleEndian.AppendUint64(expected, math.MaxInt64)
+	expected = binary.LittleEndian.AppendUint16(expected, 1)
+	expected = binary.LittleEndian.AppendUint64(expected, uint64(math.MaxUint64-6))
+	expected = binary.LittleEndian.AppendUint16(expected, 0)
+	expected = binary.LittleEndian.AppendUint32(expected, 20200102)
+	expected = append(expected, uint8(Dist))
+	if !bytes.Equal(buffer.Bytes(), expected) {
+		t.Fatalf("unexpected layout %v", buffer.Bytes())
+	}
+	reflected := new(bytes.Buffer)
+	err = core.Encode(reflected, reflectedFinanceOperation(op))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reflected.Bytes(), expected) {
+		t.Fatalf("unexpected reflection codec layout %v", reflected.Bytes())
+	}
+	op2, err := NewFinanceOperationFromBinary(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(op, op2) {
+		t.Fatal("different operations")
+	}
+	// truncated data
+	for _, l := range []int{3, 30, len(expected) - 1} {
+		_, err = NewFinanceOperationFromBinary(bytes.NewReader(expected[:l]))
+		if err == nil {
+			t.Fatalf("error expected for %v bytes", l)
+		}
+	}
+}
+
+// fuzzBinary checks that decode returns only DecodeError for any data, seeds are added with and without
+// the file header
+func fuzzBinary(f *testing.F, decode func(data []byte) error, seeds ...core.BinaryData) {
+	for _, seed := range seeds {
+		buffer := new(bytes.Buffer)
+		err := seed.Save(buffer)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buffer.Bytes())
+		buffer = new(bytes.Buffer)
+		err = core.SaveBinaryStream(buffer, nil, seed)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buffer.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		err := decode(data)
+		var decodeErr *core.DecodeError
+		if err != nil && !errors.As(err, &decodeErr) {
+			t.Fatalf("DecodeError expected: %v", err)
+		}
+	})
+}
+
+type operationData FinanceOperation
+
+func (op operationData) Save(writer io.Writer) error {
+	return FinanceOperation(op).EncodeBinary(writer)
+}
+
+func FuzzFinanceOperationBinary(f *testing.F) {
+	n := 1
+	s := "s"
+	amount := Decimal(2)
+	fuzzBinary(f, func(data []byte) error {
+		_, err := core.LoadBinaryData(data, nil, NewFinanceOperationFromBinary)
+		return err
+	}, operationData{Date: 20200101, Id: 1, Amount: &amount, FinOpProperties: []FinOpProperty{{&n, &s, 0, Typ}}})
+}
@@ -3,12 +3,17 @@ package entities
 import (
 	"TimeSeriesData/core"
 	"encoding/binary"
+	"errors"
 	"io"
+	"maps"
+	"slices"
 )
 
 type FinanceRecord struct {
 	operations []FinanceOperation
 	totals     map[int]int
+	// id of the next added operation, ids of deleted operations are not reused
+	nextId int
 }
 
 type OpsAndChanges struct {
@@ -17,26 +22,26 @@ type OpsAndChanges struct {
 }
 
 func (c OpsAndChanges) Save(writer io.Writer) error {
-	err := binary.Write(writer, binary.LittleEndian, uint16(len(c.Operations)))
+	err := core.WriteLength(writer, len(c.Operations))
 	if err != nil {
 		return err
 	}
 	for _, op := range c.Operations {
-		err = op.SaveToBinary(writer)
+		err = op.EncodeBinary(writer)
 		if err != nil {
 			return err
 		}
 	}
-	err = binary.Write(writer, binary.LittleEndian, uint16(len(c.Changes)))
+	err = core.WriteLength(writer, len(c.Changes))
 	if err != nil {
 		return err
 	}
-	for accountId, change := range c.Changes {
-		err = binary.Write(writer, binary.LittleEndian, uint16(accountId))
+	for _, accountId := range slices.Sorted(maps.Keys(c.Changes)) {
+		err = core.WriteLength(writer, accountId)
 		if err != nil {
 			return err
 		}
-		err = change.SaveToBinary(writer)
+		err = c.Changes[accountId].SaveToBinary(writer)
 		if err != nil {
 			return err
 		}
@@ -48,6 +53,7 @@ func NewFinanceRecord(operations []FinanceOperation) *FinanceRecord {
 	return &FinanceRecord{
 		operations: operations,
 		totals:     make(map[int]int),
+		nextId:     maxOperationId(operations) + 1,
 	}
 }
 
@@ -75,8 +81,20 @@ func BuildTotals(changes map[int]*FinanceChange) map[int]int {
 	return totals
 }
 
-func (r *FinanceRecord) SetTotals(changes map[int]*FinanceChange) {
-	r.totals = BuildTotals(changes)
+// SetTotals sets record totals from changes and returns true when totals were changed
+func (r *FinanceRecord) SetTotals(changes map[int]*FinanceChange) bool {
+	totals := BuildTotals(changes)
+	changed := len(totals) != len(r.totals)
+	if !changed {
+		for k, v := range totals {
+			if t, ok := r.totals[k]; !ok || t != v {
+				changed = true
+				break
+			}
+		}
+	}
+	r.totals = totals
+	return changed
 }
 
 func (r *FinanceRecord) UpdateChanges(changes map[int]*FinanceChange, accounts core.DictionaryData[Account],
@@ -131,7 +149,7 @@ func doFilterChanges(changes map[int]*FinanceChange, accounts core.DictionaryDat
 		if err != nil {
 			return nil, err
 		}
-		if acc.ActiveTo > 0 && int(acc.ActiveTo) <= date {
+		if !acc.IsActive(date) {
 			continue
 		}
 		result[accountId] = change
@@ -139,31 +157,46 @@ func doFilterChanges(changes map[int]*FinanceChange, accounts core.DictionaryDat
 	return result, nil
 }
 
+func (r *FinanceRecord) FileKind() core.FileKind {
+	return FinanceRecordFileKind
+}
+
+// financeRecordNextIdVersion is the first schema version that keeps the next operation id
+const financeRecordNextIdVersion = 3
+
+// SchemaVersion 2 writes lengths and account ids as varints, version 3 writes the next operation id after totals
+func (r *FinanceRecord) SchemaVersion() int {
+	return financeRecordNextIdVersion
+}
+
 func (r *FinanceRecord) Save(writer io.Writer) error {
-	err := binary.Write(writer, binary.LittleEndian, uint16(len(r.operations)))
+	err := core.WriteLength(writer, len(r.operations))
 	if err != nil {
 		return err
 	}
 	for _, op := range r.operations {
-		err = op.SaveToBinary(writer)
+		err = op.EncodeBinary(writer)
 		if err != nil {
 			return err
 		}
 	}
-	err = binary.Write(writer, binary.LittleEndian, uint16(len(r.totals)))
+	err = core.WriteLength(writer, len(r.totals))
 	if err != nil {
 		return err
 	}
-	for k, v := range r.totals {
-		err = binary.Write(writer, binary.LittleEndian, uint16(k))
+	for _, k := range slices.Sorted(maps.Keys(r.totals)) {
+		err = core.WriteLength(writer, k)
 		if err != nil {
 			return err
 		}
-		err = binary.Write(writer, binary.LittleEndian, int64(v))
+		err = binary.Write(writer, binary.LittleEndian, int64(r.totals[k]))
 		if err != nil {
 			return err
 		}
 	}
+	if core.WriterSchemaVersion(writer) >= financeRecordNextIdVersion {
+		return core.WriteLength(writer, r.nextId)
+	}
 	return nil
 }
 
@@ -188,6 +221,7 @@ func (r *FinanceRecord) Copy(from, to int) *FinanceRecord {
 	return &FinanceRecord{
 		operations: r.GetOperations(from, to),
 		totals:     r.totals,
+		nextId:     r.nextId,
 	}
 }
 
@@ -205,30 +239,101 @@ func (r *FinanceRecord) AddOperations(operations []FinanceOperation) {
 	r.operations = append(r.operations, operations...)
 }
 
+// EstimateSize returns rough estimate of memory used by the record in bytes
+func (r *FinanceRecord) EstimateSize() int {
+	size := 64 + len(r.totals)*32
+	for i := range r.operations {
+		size += r.operations[i].estimateSize()
+	}
+	return size
+}
+
+func maxOperationId(operations []FinanceOperation) int {
+	id := 0
+	for _, op := range operations {
+		id = max(id, op.Id)
+	}
+	return id
+}
+
+func (r *FinanceRecord) findOperation(id int) int {
+	for i, op := range r.operations {
+		if op.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *FinanceRecord) ContainsOperation(predicate func(op *FinanceOperation) bool) bool {
+	for i := range r.operations {
+		if predicate(&r.operations[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *FinanceRecord) HasOperation(id int) bool {
+	return r.findOperation(id) >= 0
+}
+
+// AddOperation assigns a new id to the operation and appends it to the record
+func (r *FinanceRecord) AddOperation(operation FinanceOperation) int {
+	operation.Id = r.nextId
+	r.nextId++
+	r.operations = append(r.operations, operation)
+	return operation.Id
+}
+
+// ModifyOperation replaces the operation with the same id
+func (r *FinanceRecord) ModifyOperation(operation FinanceOperation) error {
+	i := r.findOperation(operation.Id)
+	if i < 0 {
+		return errors.New("operation not found")
+	}
+	r.operations[i] = operation
+	return nil
+}
+
+func (r *FinanceRecord) DeleteOperation(id int) error {
+	i := r.findOperation(id)
+	if i < 0 {
+		return errors.New("operation not found")
+	}
+	r.operations = append(r.operations[:i], r.operations[i+1:]...)
+	return nil
+}
+
 func NewFinanceRecordFromBinary(reader io.Reader) (*FinanceRecord, error) {
 	var r FinanceRecord
-	var l uint16
-	err := binary.Read(reader, binary.LittleEndian, &l)
+	l, err := core.ReadArrayLength(reader)
 	if err != nil {
 		return nil, err
 	}
+	// operations in files without the header have no ids
+	legacy := core.IsHeaderlessFile(reader)
 	for l > 0 {
 		var op FinanceOperation
-		op, err = NewFinanceOperationFromBinary(reader)
+		if legacy {
+			op, err = newLegacyFinanceOperationFromBinary(reader, len(r.operations)+1)
+		} else {
+			op, err = NewFinanceOperationFromBinary(reader)
+		}
 		if err != nil {
 			return nil, err
 		}
 		r.operations = append(r.operations, op)
 		l--
 	}
-	err = binary.Read(reader, binary.LittleEndian, &l)
+	l, err = core.ReadArrayLength(reader)
 	if err != nil {
 		return nil, err
 	}
 	r.totals = make(map[int]int)
 	for l > 0 {
-		var k uint16
-		err = binary.Read(reader, binary.LittleEndian, &k)
+		var k int
+		k, err = core.ReadLength(reader)
 		if err != nil {
 			return nil, err
 		}
@@ -237,8 +342,16 @@ func NewFinanceRecordFromBinary(reader io.Reader) (*FinanceRecord, error) {
 		if err != nil {
 			return nil, err
 		}
-		r.totals[int(k)] = int(v)
+		r.totals[k] = int(v)
 		l--
 	}
+	if core.SchemaVersion(reader) >= financeRecordNextIdVersion {
+		r.nextId, err = core.ReadLength(reader)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// older versions have no counter
+	r.nextId = max(r.nextId, maxOperationId(r.operations)+1)
 	return &r, nil
 }
@@ -1,7 +1,9 @@
 package entities
 
 import (
+	"TimeSeriesData/core"
 	"bytes"
+	"math"
 	"reflect"
 	"strconv"
 	"testing"
@@ -45,3 +47,168 @@ func TestFinanceRecordBinary(t *testing.T) {
 		t.Fatal("different objects")
 	}
 }
+
+func TestFinanceRecordOperationIds(t *testing.T) {
+	r := NewFinanceRecord(nil)
+	for i := 1; i <= 3; i++ {
+		if id := r.AddOperation(FinanceOperation{Date: 20200101, Summa: Decimal(i)}); id != i {
+			t.Fatalf("wrong operation id %v", id)
+		}
+	}
+	err := r.DeleteOperation(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.HasOperation(2) {
+		t.Fatal("operation 2 should be deleted")
+	}
+	err = r.ModifyOperation(FinanceOperation{Id: 3, Date: 20200102, Summa: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := new(bytes.Buffer)
+	err = r.Save(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := NewFinanceRecordFromBinary(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := r2.GetOperations(0, 99999999)
+	if len(ops) != 2 || ops[0].Id != 1 || ops[1].Id != 3 || ops[1].Summa != 10 {
+		t.Fatal("wrong operations")
+	}
+	if id := r2.AddOperation(FinanceOperation{Date: 20200101}); id != 4 {
+		t.Fatalf("wrong operation id %v", id)
+	}
+	// ids of deleted operations are not reused after reload
+	err = r2.DeleteOperation(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	err = core.SaveBinaryStream(b, nil, r2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r3, err := core.LoadBinaryStreamP(b, nil, NewFinanceRecordFromBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := r3.AddOperation(FinanceOperation{Date: 20200101}); id != 5 {
+		t.Fatalf("wrong operation id %v", id)
+	}
+}
+
+func TestFinanceRecordLegacyLayout(t *testing.T) {
+	amount := Decimal(3)
+	legacyOps := []legacyFinanceOperation{
+		{Date: 20200101, AccountId: 1, SubcategoryId: 2, Summa: 10, Amount: &amount},
+		{Date: 20200102, AccountId: 3, SubcategoryId: 4, Summa: 20,
+			FinOpProperties: []FinOpProperty{{DateValue: 20200103, PropertyCode: Ppto}}},
+	}
+	// file without the header
+	buffer := new(bytes.Buffer)
+	err := core.WriteLength(buffer, len(legacyOps))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range legacyOps {
+		err = core.Encode(buffer, op)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	buffer.Write([]byte{1, 0, 1, 0, 5, 0, 0, 0, 0, 0, 0, 0})
+	r, err := core.LoadBinaryDataP(buffer.Bytes(), nil, NewFinanceRecordFromBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []FinanceOperation{
+		{Date: 20200101, Id: 1, AccountId: 1, SubcategoryId: 2, Summa: 10, Amount: &amount},
+		{Date: 20200102, Id: 2, AccountId: 3, SubcategoryId: 4, Summa: 20,
+			FinOpProperties: legacyOps[1].FinOpProperties},
+	}
+	if !reflect.DeepEqual(r.operations, expected) || r.totals[1] != 5 {
+		t.Fatalf("unexpected record %v", r)
+	}
+	if id := r.AddOperation(FinanceOperation{Date: 20200101}); id != 3 {
+		t.Fatalf("wrong operation id %v", id)
+	}
+}
+
+func TestFinanceRecordSaveIsDeterministic(t *testing.T) {
+	r := NewFinanceRecord([]FinanceOperation{{Date: 20200101, Id: 1, Summa: 5}})
+	for i := range 100 {
+		r.totals[i] = i * 10
+	}
+	expected := new(bytes.Buffer)
+	err := r.Save(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := NewFinanceRecordFromBinary(bytes.NewReader(expected.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := OpsAndChanges{Operations: r.operations, Changes: CreateChanges(r.totals)}
+	expectedChanges := new(bytes.Buffer)
+	err = changes.Save(expectedChanges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 10 {
+		b := new(bytes.Buffer)
+		err = r2.Save(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b.Bytes(), expected.Bytes()) {
+			t.Fatal("saved record should be byte-identical")
+		}
+		b.Reset()
+		err = OpsAndChanges{Operations: r2.operations, Changes: CreateChanges(r2.totals)}.Save(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b.Bytes(), expectedChanges.Bytes()) {
+			t.Fatal("saved changes should be byte-identical")
+		}
+	}
+}
+
+func TestFinanceRecordVarintFormat(t *testing.T) {
+	ops := make([]FinanceOperation, math.MaxUint16+1)
+	for i := range ops {
+		ops[i] = FinanceOperation{Date: 20200101, Id: i + 1, AccountId: math.MaxUint16 + 1, Summa: Decimal(i)}
+	}
+	r := NewFinanceRecord(ops)
+	r.totals[math.MaxUint16+1] = 5
+	buffer := new(bytes.Buffer)
+	err := core.SaveBinaryStream(buffer, nil, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := core.LoadBinaryStreamP(buffer, nil, NewFinanceRecordFromBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r2.operations) != len(ops) || r2.totals[math.MaxUint16+1] != 5 {
+		t.Fatal("different objects")
+	}
+	// schema version 1 format
+	err = r.Save(new(bytes.Buffer))
+	if err == nil {
+		t.Fatal("overflow should be detected")
+	}
+}
+
+func FuzzFinanceRecordBinary(f *testing.F) {
+	r := NewFinanceRecord([]FinanceOperation{{Date: 20200101, Id: 1, Summa: 5}})
+	r.totals[1] = 2
+	fuzzBinary(f, func(data []byte) error {
+		_, err := core.LoadBinaryDataP(data, nil, NewFinanceRecordFromBinary)
+		return err
+	}, r)
+}
@@ -2,7 +2,6 @@ package entities
 
 import (
 	"TimeSeriesData/core"
-	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"io"
@@ -85,97 +84,37 @@ func (n *SubcategoryOperationCode) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+//go:generate go run TimeSeriesData/cmd/binarygen -type Subcategory
+
 type Subcategory struct {
-	Id                 int
-	Code               SubcategoryCode
-	Name               string
-	OperationCodeId    SubcategoryOperationCode
-	CategoryId         int
-	RequiredProperties []FinOpPropertyCode
+	Id                 int                      `bin:"u32"`
+	Name               string                   `bin:"string"`
+	CategoryId         int                      `bin:"u32"`
+	Code               SubcategoryCode          `bin:"u8"`
+	OperationCodeId    SubcategoryOperationCode `bin:"u8"`
+	RequiredProperties []FinOpPropertyCode      `bin:"[]u8"`
 }
 
 func (s Subcategory) GetId() int {
 	return s.Id
 }
 
+func (s Subcategory) FileKind() core.FileKind {
+	return SubcategoriesFileKind
+}
+
+func (s Subcategory) SchemaVersion() int {
+	return 2
+}
+
 func (s Subcategory) Save(writer io.Writer) error {
-	err := binary.Write(writer, binary.LittleEndian, uint32(s.Id))
-	if err != nil {
-		return err
-	}
-	err = core.WriteStringToBinary(writer, s.Name)
-	if err != nil {
-		return err
-	}
-	err = binary.Write(writer, binary.LittleEndian, uint32(s.CategoryId))
-	if err != nil {
-		return err
-	}
-	err = binary.Write(writer, binary.LittleEndian, uint8(s.Code))
-	if err != nil {
-		return err
-	}
-	err = binary.Write(writer, binary.LittleEndian, uint8(s.OperationCodeId))
-	if err != nil {
-		return err
-	}
-	l := uint16(len(s.RequiredProperties))
-	err = binary.Write(writer, binary.LittleEndian, l)
-	if err != nil {
-		return err
-	}
-	for _, prop := range s.RequiredProperties {
-		err = binary.Write(writer, binary.LittleEndian, uint8(prop))
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return s.EncodeBinary(writer)
 }
 
 func NewSubcategoryFromBinary(reader io.Reader) (Subcategory, error) {
-	var id uint32
-	err := binary.Read(reader, binary.LittleEndian, &id)
-	if err != nil {
-		return Subcategory{}, nil
-	}
-	var name string
-	name, err = core.ReadStringFromBinary(reader)
-	if err != nil {
-		return Subcategory{}, nil
-	}
-	var categoryId uint32
-	err = binary.Read(reader, binary.LittleEndian, &categoryId)
-	if err != nil {
-		return Subcategory{}, nil
-	}
-	var code uint8
-	err = binary.Read(reader, binary.LittleEndian, &code)
-	if err != nil {
-		return Subcategory{}, nil
-	}
-	var operationCode uint8
-	err = binary.Read(reader, binary.LittleEndian, &operationCode)
-	if err != nil {
-		return Subcategory{}, nil
-	}
-	var l uint16
-	err = binary.Read(reader, binary.LittleEndian, &l)
-	if err != nil {
-		return Subcategory{}, nil
-	}
-	var requiredProperties []FinOpPropertyCode
-	for l > 0 {
-		var prop uint8
-		err = binary.Read(reader, binary.LittleEndian, &prop)
-		if err != nil {
-			return Subcategory{}, nil
-		}
-		requiredProperties = append(requiredProperties, FinOpPropertyCode(prop))
-		l--
-	}
-	return Subcategory{Id: int(id), Name: name, CategoryId: int(categoryId), Code: SubcategoryCode(code),
-		OperationCodeId: SubcategoryOperationCode(operationCode), RequiredProperties: requiredProperties}, nil
+	var s Subcategory
+	err := s.DecodeBinary(reader)
+	return s, err
 }
 
 func SaveSubcategoryByIndex(index int, value any, writer io.Writer) error {
@@ -0,0 +1,92 @@
+// Code generated by binarygen; DO NOT EDIT.
+
+package entities
+
+import (
+	"TimeSeriesData/core"
+	"io"
+)
+
+func (v Subcategory) EncodeBinary(writer io.Writer) error {
+	if err := core.WriteInteger(writer, core.BinaryUint32, v.Id); err != nil {
+		return err
+	}
+	if err := core.WriteStringToBinary(writer, v.Name); err != nil {
+		return err
+	}
+	if err := core.WriteInteger(writer, core.BinaryUint32, v.CategoryId); err != nil {
+		return err
+	}
+	if err := core.WriteInteger(writer, core.BinaryUint8, v.Code); err != nil {
+		return err
+	}
+	if err := core.WriteInteger(writer, core.BinaryUint8, v.OperationCodeId); err != nil {
+		return err
+	}
+	if err := core.WriteLength(writer, len(v.RequiredProperties)); err != nil {
+		return err
+	}
+	for _, e := range v.RequiredProperties {
+		if err := core.WriteInteger(writer, core.BinaryUint8, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Subcategory) DecodeBinary(reader io.Reader) error {
+	{
+		x, err := core.ReadUint32(reader)
+		if err != nil {
+			return err
+		}
+		v.Id = int(x)
+	}
+	{
+		x, err := core.ReadStringFromBinary(reader)
+		if err != nil {
+			return err
+		}
+		v.Name = x
+	}
+	{
+		x, err := core.ReadUint32(reader)
+		if err != nil {
+			return err
+		}
+		v.CategoryId = int(x)
+	}
+	{
+		x, err := core.ReadUint8(reader)
+		if err != nil {
+			return err
+		}
+		v.Code = SubcategoryCode(x)
+	}
+	{
+		x, err := core.ReadUint8(reader)
+		if err != nil {
+			return err
+		}
+		v.OperationCodeId = SubcategoryOperationCode(x)
+	}
+	{
+		n, err := core.ReadArrayLength(reader)
+		if err != nil {
+			return err
+		}
+		v.RequiredProperties = nil
+		for ; n > 0; n-- {
+			var e FinOpPropertyCode
+			{
+				x, err := core.ReadUint8(reader)
+				if err != nil {
+					return err
+				}
+				e = FinOpPropertyCode(x)
+			}
+			v.RequiredProperties = append(v.RequiredProperties, e)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package entities
 
 import (
+	"TimeSeriesData/core"
 	"bytes"
 	"reflect"
 	"testing"
@@ -31,3 +32,10 @@ func TestSubcategoryBinary(t *testing.T) {
 		t.Fatal("non zero length")
 	}
 }
+
+func FuzzSubcategoryBinary(f *testing.F) {
+	fuzzBinary(f, func(data []byte) error {
+		_, err := core.LoadBinaryData(data, nil, NewSubcategoryFromBinary)
+		return err
+	}, Subcategory{Id: 1, Name: "subcategory1", RequiredProperties: []FinOpPropertyCode{Seca, Dist}})
+}
@@ -5,6 +5,9 @@ import (
 	"TimeSeriesData/core"
 	"encoding/binary"
 	"io"
+	"maps"
+	"os"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -44,6 +47,18 @@ func (b *binaryDatedSource) Save(date int, data *entities.FinanceRecord, dataFol
 	return core.SaveBinary(b.getFileName(date, dataFolderPath), b.processor, data)
 }
 
+func (b *binaryDatedSource) Delete(date int, dataFolderPath string) error {
+	err := os.Remove(b.getFileName(date, dataFolderPath))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (b *binaryDatedSource) EstimateSize(data *entities.FinanceRecord) int {
+	return data.EstimateSize()
+}
+
 type binaryDBConfiguration struct {
 	processor core.CryptoProcessor
 }
@@ -53,14 +68,21 @@ type hintsItem struct {
 	hints map[string]bool
 }
 
+func (h hintsItem) FileKind() core.FileKind {
+	return entities.HintsFileKind
+}
+
+func (h hintsItem) SchemaVersion() int {
+	return 2
+}
+
 func newHintsItem(reader io.Reader) (hintsItem, error) {
 	var code uint8
 	err := binary.Read(reader, binary.LittleEndian, &code)
 	if err != nil {
 		return hintsItem{}, err
 	}
-	var l uint16
-	err = binary.Read(reader, binary.LittleEndian, &l)
+	l, err := core.ReadArrayLength(reader)
 	if err != nil {
 		return hintsItem{}, err
 	}
@@ -105,22 +127,29 @@ func (b binaryDBConfiguration) GetHints(fileName string) (dbHints, error) {
 	return result, nil
 }
 
+func (h dbHints) FileKind() core.FileKind {
+	return entities.HintsFileKind
+}
+
+func (h dbHints) SchemaVersion() int {
+	return 2
+}
+
 func (h dbHints) Save(writer io.Writer) error {
-	l := uint16(len(h))
-	err := binary.Write(writer, binary.LittleEndian, l)
+	err := core.WriteLength(writer, len(h))
 	if err != nil {
 		return err
 	}
-	for k, v := range h {
+	for _, k := range slices.Sorted(maps.Keys(h)) {
 		err = binary.Write(writer, binary.LittleEndian, uint8(k))
 		if err != nil {
 			return err
 		}
-		err = binary.Write(writer, binary.LittleEndian, uint16(len(v)))
+		err = core.WriteLength(writer, len(h[k]))
 		if err != nil {
 			return err
 		}
-		for hint := range v {
+		for _, hint := range slices.Sorted(maps.Keys(h[k])) {
 			err = core.WriteStringToBinary(writer, hint)
 			if err != nil {
 				return err
@@ -159,3 +188,10 @@ func (b binaryDBConfiguration) GetSubcategories(fileName, _ string) ([]entities.
 func (b binaryDBConfiguration) GetMainDataSource() core.DatedSource[entities.FinanceRecord] {
 	return &binaryDatedSource{b.processor}
 }
+
+func (b binaryDBConfiguration) GetTimeSeriesDataOptions() []core.TimeSeriesDataOption[entities.FinanceRecord] {
+	return []core.TimeSeriesDataOption[entities.FinanceRecord]{
+		core.WithJournal[entities.FinanceRecord](b.processor,
+			core.NewBinaryJournalCodec(entities.NewFinanceRecordFromBinary)),
+	}
+}
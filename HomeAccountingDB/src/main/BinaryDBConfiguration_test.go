@@ -3,25 +3,71 @@ package main
 import (
 	"HomeAccountingDB/src/entities"
 	"TimeSeriesData/core"
+	"bytes"
+	"errors"
 	"reflect"
+	"strconv"
 	"testing"
 )
 
+func saveHints(t *testing.T, config binaryDBConfiguration, hints dbHints) []byte {
+	saver := config.GetSaver()
+	err := saver.Save(hints, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := saver.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
 func TestHints(t *testing.T) {
 	hints := make(dbHints)
 	hints[entities.Typ] = map[string]bool{"Type1": true, "Type2": true}
 	hints[entities.Netw] = map[string]bool{"Netw1": true, "Netw2": true}
-	config := binaryDBConfiguration{}
-	saver, _ := config.GetSaver().(*core.BinarySaver)
-	err := saver.Save(hints, nil)
-	if err != nil {
-		t.Fatal(err)
+	hints[entities.Seca] = make(map[string]bool)
+	for i := range 50 {
+		hints[entities.Seca][strconv.Itoa(i)] = true
 	}
-	loaded, err := config.getHintsFromData(saver.GetBytes())
+	config := binaryDBConfiguration{}
+	data := saveHints(t, config, hints)
+	loaded, err := config.getHintsFromData(data)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if !reflect.DeepEqual(hints, loaded) {
 		t.Fatal("different data")
 	}
+	for range 10 {
+		if !bytes.Equal(saveHints(t, config, loaded), data) {
+			t.Fatal("saved hints should be byte-identical")
+		}
+	}
+}
+
+func FuzzHints(f *testing.F) {
+	hints := make(dbHints)
+	hints[entities.Typ] = map[string]bool{"Type1": true}
+	config := binaryDBConfiguration{}
+	saver, _ := config.GetSaver().(*core.BinarySaver)
+	err := saver.Save(hints, nil)
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, getBytes := range []func() ([]byte, error){saver.GetBytes, saver.GetFileBytes} {
+		data, err := getBytes()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, err := config.getHintsFromData(data)
+		var decodeErr *core.DecodeError
+		if err != nil && !errors.As(err, &decodeErr) {
+			t.Fatalf("DecodeError expected: %v", err)
+		}
+	})
 }
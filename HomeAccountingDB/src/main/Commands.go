@@ -9,6 +9,14 @@ import (
 	"fmt"
 )
 
+// requestDecodeLimits limits strings and arrays decoded from requests
+var requestDecodeLimits = core.DecodeLimits{MaxStringLength: 1024, MaxArrayLength: 16}
+
+// readString reads a string from the request applying requestDecodeLimits
+func readString(buffer *bytes.Buffer) (string, error) {
+	return core.ReadStringFromBinary(core.NewDecodeReader(buffer, requestDecodeLimits))
+}
+
 type dictsCommand struct{}
 
 func newDictsCommand(buffer *bytes.Buffer) (command, error) {
@@ -46,7 +54,7 @@ func (c *opsCommand) Execute(db *dB) ([]byte, error) {
 }
 
 func (c *opsCommand) ReadOnlyLockRequired() bool {
-	return false
+	return true
 }
 
 type opsRangeCommand struct {
@@ -74,7 +82,7 @@ func (c *opsRangeCommand) Execute(db *dB) ([]byte, error) {
 }
 
 func (c *opsRangeCommand) ReadOnlyLockRequired() bool {
-	return false
+	return true
 }
 
 type addOperationCommand struct {
@@ -86,59 +94,66 @@ type addOperationCommand struct {
 	properties  []entities.FinOpProperty
 }
 
-func newAddOperationCommand(buffer *bytes.Buffer, name string) (command, error) {
+func readOperation(buffer *bytes.Buffer, name string) (addOperationCommand, error) {
 	if buffer.Len() < 18 {
-		return nil, fmt.Errorf("invalid %v command", name)
+		return addOperationCommand{}, fmt.Errorf("invalid %v command", name)
 	}
 	var date uint32
 	err := binary.Read(buffer, binary.LittleEndian, &date)
 	if err != nil {
-		return nil, err
+		return addOperationCommand{}, err
 	}
 	var subcategory uint32
 	err = binary.Read(buffer, binary.LittleEndian, &subcategory)
 	if err != nil {
-		return nil, err
+		return addOperationCommand{}, err
 	}
 	var account uint32
 	err = binary.Read(buffer, binary.LittleEndian, &account)
 	if err != nil {
-		return nil, err
+		return addOperationCommand{}, err
 	}
-	summa, err := core.ReadStringFromBinary(buffer)
+	reader := core.NewDecodeReader(buffer, requestDecodeLimits)
+	summa, err := core.ReadStringFromBinary(reader)
 	if err != nil {
-		return nil, err
+		return addOperationCommand{}, err
 	}
-	amount, err := core.ReadStringFromBinary(buffer)
+	amount, err := core.ReadStringFromBinary(reader)
 	if err != nil {
-		return nil, err
+		return addOperationCommand{}, err
 	}
-	var l uint16
-	err = binary.Read(buffer, binary.LittleEndian, &l)
+	l, err := core.ReadArrayLength(reader)
 	if err != nil {
-		return nil, err
+		return addOperationCommand{}, err
 	}
 	var properties []entities.FinOpProperty
 	for l > 0 {
-		prop, err := entities.NewFinOpPropertyFromBinary(buffer)
+		prop, err := entities.NewFinOpPropertyFromBinary(reader)
 		if err != nil {
-			return nil, err
+			return addOperationCommand{}, err
 		}
 		properties = append(properties, prop)
 		l--
 	}
 	if buffer.Len() > 0 {
-		return nil, fmt.Errorf("incorrect %v command length", name)
+		return addOperationCommand{}, fmt.Errorf("incorrect %v command length", name)
 	}
-	c := addOperationCommand{
+	return addOperationCommand{
 		date:        int(date),
 		subcategory: int(subcategory),
 		account:     int(account),
 		summa:       summa,
 		amount:      amount,
-		properties:  nil,
+		properties:  properties,
+	}, nil
+}
+
+func newAddOperationCommand(buffer *bytes.Buffer) (command, error) {
+	c, err := readOperation(buffer, "addOperation")
+	if err != nil {
+		return nil, err
 	}
-	fmt.Printf("%v command %v\n", name, c)
+	fmt.Printf("addOperation command %v\n", c)
 	return &c, nil
 }
 
@@ -150,13 +165,34 @@ func (c *addOperationCommand) ReadOnlyLockRequired() bool {
 	return false
 }
 
-type modifyOperationCommand addOperationCommand
+type modifyOperationCommand struct {
+	// date and id of the operation to be modified
+	date      int
+	id        int
+	operation addOperationCommand
+}
 
 func newModifyOperationCommand(buffer *bytes.Buffer) (command, error) {
-	c, err := newAddOperationCommand(buffer, "modifyOperation")
-	ac := c.(*addOperationCommand)
-	mc := modifyOperationCommand(*ac)
-	return &mc, err
+	if buffer.Len() < 8 {
+		return nil, errors.New("invalid modifyOperation command")
+	}
+	var date uint32
+	err := binary.Read(buffer, binary.LittleEndian, &date)
+	if err != nil {
+		return nil, err
+	}
+	var id uint32
+	err = binary.Read(buffer, binary.LittleEndian, &id)
+	if err != nil {
+		return nil, err
+	}
+	operation, err := readOperation(buffer, "modifyOperation")
+	if err != nil {
+		return nil, err
+	}
+	c := modifyOperationCommand{date: int(date), id: int(id), operation: operation}
+	fmt.Printf("modifyOperation command %v\n", c)
+	return &c, nil
 }
 
 func (c *modifyOperationCommand) Execute(db *dB) ([]byte, error) {
@@ -167,14 +203,31 @@ func (c *modifyOperationCommand) ReadOnlyLockRequired() bool {
 	return false
 }
 
+type statsCommand struct{}
+
+func newStatsCommand(buffer *bytes.Buffer) (command, error) {
+	if buffer.Len() != 0 {
+		return nil, errors.New("invalid stats command")
+	}
+	fmt.Println("stats command")
+	return &statsCommand{}, nil
+}
+
+func (c *statsCommand) Execute(db *dB) ([]byte, error) {
+	return db.getStats()
+}
+
+func (c *statsCommand) ReadOnlyLockRequired() bool {
+	return true
+}
+
 type deleteOperationCommand struct {
-	date        int
-	subcategory int
-	account     int
+	date int
+	id   int
 }
 
 func newDeleteOperationCommand(buffer *bytes.Buffer) (command, error) {
-	if buffer.Len() != 12 {
+	if buffer.Len() != 8 {
 		return nil, errors.New("invalid deleteOperation command")
 	}
 	var date uint32
@@ -182,15 +235,10 @@ func newDeleteOperationCommand(buffer *bytes.Buffer) (command, error) {
 	if err != nil {
 		return nil, err
 	}
-	var subcategory uint32
-	err = binary.Read(buffer, binary.LittleEndian, &subcategory)
-	if err != nil {
-		return nil, err
-	}
-	var account uint32
-	err = binary.Read(buffer, binary.LittleEndian, &account)
-	fmt.Printf("deleteOperation command, date=%v subcategory=%v account=%v\n", date, subcategory, account)
-	return &deleteOperationCommand{int(date), int(subcategory), int(account)}, err
+	var id uint32
+	err = binary.Read(buffer, binary.LittleEndian, &id)
+	fmt.Printf("deleteOperation command, date=%v id=%v\n", date, id)
+	return &deleteOperationCommand{int(date), int(id)}, err
 }
 
 func (c *deleteOperationCommand) Execute(db *dB) ([]byte, error) {
@@ -3,10 +3,12 @@ package main
 import (
 	"HomeAccountingDB/src/entities"
 	"TimeSeriesData/core"
-	"errors"
 	"fmt"
 	"github.com/sergz72/expreval"
-	"os"
+	"log"
+	"math"
+	"sync"
+	"time"
 )
 
 const parserStackSize = 100
@@ -15,9 +17,15 @@ type settings struct {
 	MinYear                int
 	MinMonth               int
 	TimeSeriesDataCapacity int
-	DataFolderPath         string
-	ServerPort             int
-	Key                    string
+	// limits estimated size of loaded months, zero disables the limit
+	MaxActiveTimeSeriesBytes int
+	// background save interval in seconds, zero value saves modified months immediately
+	FlushInterval int
+	// minimum age of modification in seconds for background save
+	MaxDirtyAge    int
+	DataFolderPath string
+	ServerPort     int
+	Key            string
 }
 
 type dBConfiguration interface {
@@ -27,6 +35,7 @@ type dBConfiguration interface {
 	GetHints(fileName string) (dbHints, error)
 	GetMainDataSource() core.DatedSource[entities.FinanceRecord]
 	GetSaver() core.DataSaver
+	GetTimeSeriesDataOptions() []core.TimeSeriesDataOption[entities.FinanceRecord]
 }
 
 type dbHints map[entities.FinOpPropertyCode]map[string]bool
@@ -37,8 +46,10 @@ type dB struct {
 	accounts       core.DictionaryData[entities.Account]
 	categories     core.DictionaryData[entities.Category]
 	subcategories  core.DictionaryData[entities.Subcategory]
-	data           core.TimeSeriesData[entities.FinanceRecord]
+	data           *core.TimeSeriesData[entities.FinanceRecord]
 	hints          dbHints
+	// modified months are saved by the background flusher
+	flusherEnabled bool
 }
 
 func getAccountsFileName(dataFolderPath string) string {
@@ -114,7 +125,7 @@ func loadDB(s settings, configuration dBConfiguration) (*dB, error) {
 			return indexCalculator(date, s.MinYear, s.MinMonth)
 		}, func(date int) int {
 			return date / 100
-		}, 1000000)
+		}, 1000000, core.WithLoadProgress[entities.FinanceRecord](core.PrintLoadProgress))
 	if err != nil {
 		return nil, err
 	}
@@ -122,47 +133,109 @@ func loadDB(s settings, configuration dBConfiguration) (*dB, error) {
 		categories: categories, subcategories: subcategories, data: data, hints: hints}, nil
 }
 
-func initDB(s settings, configuration dBConfiguration) (*dB, error) {
+func getTimeSeriesDataOptions(s settings, configuration dBConfiguration,
+	locker sync.Locker) []core.TimeSeriesDataOption[entities.FinanceRecord] {
+	options := append(configuration.GetTimeSeriesDataOptions(),
+		core.WithMaxActiveBytes[entities.FinanceRecord](s.MaxActiveTimeSeriesBytes))
+	if s.FlushInterval > 0 {
+		options = append(options, core.WithFlusher[entities.FinanceRecord](time.Duration(s.FlushInterval)*time.Second,
+			time.Duration(s.MaxDirtyAge)*time.Second, locker, func(err error) {
+				log.Printf("flush error %v\n", err.Error())
+			}))
+	}
+	return options
+}
+
+// initDB initializes database, locker excludes database modifications during background saves
+func initDB(s settings, configuration dBConfiguration, locker sync.Locker) (*dB, error) {
 	accounts, categories, subcategories, hints, err := loadDicts(s, configuration)
 	if err != nil {
 		return nil, err
 	}
+	// removes dictionary files left by interrupted writes, data folders are cleaned by InitTimeSeriesData
+	err = core.RemoveFolderTempFiles(s.DataFolderPath)
+	if err != nil {
+		return nil, err
+	}
 	data, err := core.InitTimeSeriesData[entities.FinanceRecord](getMainDataFolderPath(s.DataFolderPath),
 		configuration.GetMainDataSource(), s.TimeSeriesDataCapacity, func(date int) int {
 			return indexCalculator(date, s.MinYear, s.MinMonth)
 		}, func(date int) int {
 			return date / 100
-		}, 1000000)
+		}, 1000000, getTimeSeriesDataOptions(s, configuration, locker)...)
 	if err != nil {
 		return nil, err
 	}
 	return &dB{dataFolderPath: s.DataFolderPath, configuration: configuration, accounts: accounts,
-		categories: categories, subcategories: subcategories, data: data, hints: hints}, nil
+		categories: categories, subcategories: subcategories, data: data, hints: hints,
+		flusherEnabled: s.FlushInterval > 0}, nil
 }
 
-func (d *dB) buildTotals(from int) error {
-	i, err := d.data.Iterator(from, 99999999)
+// verifyDB decodes all dictionary and data files and returns errors for all bad files
+func verifyDB(s settings, configuration dBConfiguration) ([]core.FileError, error) {
+	var result []core.FileError
+	extension := configuration.GetSaver().GetFileExtension()
+	check := func(path string, err error) {
+		if err != nil {
+			result = append(result, core.FileError{FileName: path + extension, Err: err})
+		}
+	}
+	path := getAccountsFileName(s.DataFolderPath)
+	_, err := configuration.GetAccounts(path)
+	check(path, err)
+	path = getCategoriesFileName(s.DataFolderPath)
+	_, err = configuration.GetCategories(path)
+	check(path, err)
+	path = getSubcategoriesFileName(s.DataFolderPath)
+	_, err = configuration.GetSubcategories(path, getSubcategoriesMapFileName(s.DataFolderPath))
+	check(path, err)
+	path = getHintsFileName(s.DataFolderPath)
+	_, err = configuration.GetHints(path)
+	check(path, err)
+	data := core.NewTimeSeriesData[entities.FinanceRecord](getMainDataFolderPath(s.DataFolderPath),
+		configuration.GetMainDataSource(), s.TimeSeriesDataCapacity, func(date int) int {
+			return indexCalculator(date, s.MinYear, s.MinMonth)
+		}, func(date int) int {
+			return date / 100
+		}, 1)
+	errs, err := data.Verify()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return append(result, errs...), nil
+}
+
+func (d *dB) buildTotals(from int) error {
+	return d.updateTotals(from, 99999999)
+}
+
+// updateTotals recalculates totals of all months after the month of from date and marks changed ones as modified.
+// Months after the month of to date have no changed operations, so recalculation stops at the first of them
+// which totals are unchanged. Months are fetched one by one instead of using the iterator, because the iterator
+// loads the next month before the current one is changed, and it may evict the current month.
+func (d *dB) updateTotals(from, to int) error {
+	last := d.data.IndexCalculator(to)
 	var changes map[int]*entities.FinanceChange
-	for i.HasNext() {
-		idx, v, err := i.Next()
-		if err != nil {
+	date := from
+	for {
+		idx, v, err := d.data.Ceiling(date)
+		if err != nil || v == nil {
 			return err
 		}
 		if changes == nil {
 			changes = v.BuildChanges()
-		} else {
-			v.SetTotals(changes)
+		} else if v.SetTotals(changes) {
 			d.data.MarkAsModified(idx)
+		} else if idx > last {
+			return nil
 		}
 		err = v.UpdateChanges(changes, d.accounts, d.subcategories, 0, 99999999)
 		if err != nil {
 			return err
 		}
+		// item dates are months in yyyymm format
+		date = nextMonth(d.data.GetDate(idx)*100 + 1)
 	}
-	return nil
 }
 
 func (d *dB) printChanges(date int) {
@@ -191,6 +264,15 @@ func (d *dB) printChanges(date int) {
 	}
 }
 
+// upgrade rewrites all database files in the latest schema versions
+func (d *dB) upgrade() error {
+	err := d.data.Rewrite()
+	if err != nil {
+		return err
+	}
+	return d.save()
+}
+
 func (d *dB) save() error {
 	return d.saveTo(d.dataFolderPath, d.configuration)
 }
@@ -230,7 +312,33 @@ func (d *dB) getDicts() ([]byte, error) {
 		return nil, err
 	}
 	err = saver.Save(d.hints, nil)
-	return saver.GetBytes(), err
+	if err != nil {
+		return nil, err
+	}
+	return saver.GetBytes()
+}
+
+func (d *dB) getStats() ([]byte, error) {
+	saver := core.NewBinarySaver(nil)
+	err := saver.Save(d.data.Stats(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return saver.GetBytes()
+}
+
+// printCacheStats reads all months and prints cache and I/O counters
+func (d *dB) printCacheStats() {
+	i, err := d.data.Iterator(0, 99999999)
+	if err != nil {
+		panic(err)
+	}
+	for range i.All() {
+	}
+	if i.Err() != nil {
+		panic(i.Err())
+	}
+	fmt.Println(d.data.Stats())
 }
 
 func (d *dB) getOpsAndChanges(date int) ([]byte, error) {
@@ -252,7 +360,7 @@ func (d *dB) getOpsAndChanges(date int) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return saver.GetBytes(), nil
+	return saver.GetBytes()
 }
 
 func (d *dB) getOpsAndTotals(from, to int) ([]byte, error) {
@@ -262,11 +370,7 @@ func (d *dB) getOpsAndTotals(from, to int) ([]byte, error) {
 	}
 	record := entities.NewFinanceRecord(nil)
 	first := true
-	for i.HasNext() {
-		_, v, err := i.Next()
-		if err != nil {
-			return nil, err
-		}
+	for _, v := range i.All() {
 		if first {
 			record = v.Copy(from, to)
 			first = false
@@ -274,12 +378,15 @@ func (d *dB) getOpsAndTotals(from, to int) ([]byte, error) {
 			record.AddOperations(v.GetOperations(from, to))
 		}
 	}
+	if i.Err() != nil {
+		return nil, i.Err()
+	}
 	saver := core.NewBinarySaver(nil)
 	err = saver.Save(record, nil)
 	if err != nil {
 		return nil, err
 	}
-	return saver.GetBytes(), nil
+	return saver.GetBytes()
 }
 
 func (d *dB) buildHints() error {
@@ -288,16 +395,12 @@ func (d *dB) buildHints() error {
 		return err
 	}
 	d.hints = make(map[entities.FinOpPropertyCode]map[string]bool)
-	for i.HasNext() {
-		_, v, err := i.Next()
-		if err != nil {
-			return err
-		}
+	for _, v := range i.All() {
 		hints := v.BuildHints()
 		d.mergeHints(hints)
 	}
 
-	return nil
+	return i.Err()
 }
 
 func (d *dB) mergeHints(hints map[entities.FinOpPropertyCode]map[string]bool) {
@@ -321,28 +424,188 @@ func (d *dB) saveHints(saver core.DataSaver, fileName string) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(fileName+saver.GetFileExtension(), saver.GetBytes(), 0644)
+	data, err := saver.GetFileBytes()
+	if err != nil {
+		return err
+	}
+	return core.WriteFileAtomic(fileName+saver.GetFileExtension(), data, 0644)
 }
 
-func (d *dB) addOperation(command *addOperationCommand) ([]byte, error) {
-	_, err := expreval.Eval(command.summa, parserStackSize)
+func (d *dB) buildOperation(command *addOperationCommand) (entities.FinanceOperation, error) {
+	summa, err := expreval.Eval(command.summa, parserStackSize)
 	if err != nil {
-		return nil, err
+		return entities.FinanceOperation{}, newDBError(errorInvalidSumma, "%v", err.Error())
 	}
-	var amount *float64
+	var amount *entities.Decimal
 	if len(command.amount) > 0 {
-		*amount, err = expreval.Eval(command.amount, parserStackSize)
+		var a float64
+		a, err = expreval.Eval(command.amount, parserStackSize)
 		if err != nil {
-			return nil, err
+			return entities.FinanceOperation{}, newDBError(errorInvalidAmount, "%v", err.Error())
+		}
+		// amount is stored with 3 decimal digits
+		v := entities.Decimal(math.Round(a * 1000))
+		amount = &v
+	}
+	op := entities.FinanceOperation{
+		Date: command.date,
+		// summa is stored with 2 decimal digits
+		Summa:           entities.Decimal(math.Round(summa * 100)),
+		Amount:          amount,
+		SubcategoryId:   command.subcategory,
+		FinOpProperties: command.properties,
+		AccountId:       command.account,
+	}
+	return op, d.validateOperation(&op)
+}
+
+func previousMonth(date int) int {
+	year := date / 10000
+	month := (date / 100) % 100
+	if month == 1 {
+		return (year-1)*10000 + 1201
+	}
+	return year*10000 + (month-1)*100 + 1
+}
+
+func nextMonth(date int) int {
+	year := date / 10000
+	month := (date / 100) % 100
+	if month == 12 {
+		return (year+1)*10000 + 101
+	}
+	return year*10000 + (month+1)*100 + 1
+}
+
+func (d *dB) getOrCreateRecord(date int) (int, *entities.FinanceRecord, error) {
+	idx := d.data.IndexCalculator(date)
+	record, err := d.data.GetExact(date)
+	if err != nil || record != nil {
+		return idx, record, err
+	}
+	record = entities.NewFinanceRecord(nil)
+	_, previous, err := d.data.Floor(previousMonth(date))
+	if err != nil {
+		return idx, nil, err
+	}
+	if previous != nil {
+		changes := previous.BuildChanges()
+		err = previous.UpdateChanges(changes, d.accounts, d.subcategories, 0, 99999999)
+		if err != nil {
+			return idx, nil, err
+		}
+		record.SetTotals(changes)
+	}
+	err = d.data.Add(idx, d.data.DateCalculator(date), record)
+	return idx, record, err
+}
+
+// persist recalculates totals after operations between from and to dates were changed, writes modified months
+// to the journal and saves them
+func (d *dB) persist(from, to int, operations []entities.FinanceOperation) error {
+	err := d.updateTotals(from, to)
+	if err != nil {
+		return err
+	}
+	err = d.data.Commit()
+	if err != nil {
+		return err
+	}
+	if !d.flusherEnabled {
+		err = d.data.Save()
+		if err != nil {
+			return err
 		}
 	}
-	return nil, errors.New("not implemented")
+	d.mergeHints(entities.NewFinanceRecord(operations).BuildHints())
+	return d.saveHints(d.configuration.GetSaver(), getHintsFileName(d.dataFolderPath))
+}
+
+func (d *dB) addOperation(command *addOperationCommand) ([]byte, error) {
+	op, err := d.buildOperation(command)
+	if err != nil {
+		return nil, err
+	}
+	idx, record, err := d.getOrCreateRecord(op.Date)
+	if err != nil {
+		return nil, err
+	}
+	record.AddOperation(op)
+	d.data.MarkAsModified(idx)
+	err = d.persist(op.Date, op.Date, []entities.FinanceOperation{op})
+	if err != nil {
+		return nil, err
+	}
+	return d.getOpsAndChanges(op.Date)
+}
+
+func (d *dB) findOperationRecord(date, id int) (int, *entities.FinanceRecord, error) {
+	record, err := d.data.GetExact(date)
+	if err != nil {
+		return 0, nil, err
+	}
+	if record == nil || !record.HasOperation(id) {
+		return 0, nil, newDBError(errorOperationNotFound, "operation %v not found", id)
+	}
+	return d.data.IndexCalculator(date), record, nil
 }
 
 func (d *dB) modifyOperation(command *modifyOperationCommand) ([]byte, error) {
-	return nil, errors.New("not implemented")
+	op, err := d.buildOperation(&command.operation)
+	if err != nil {
+		return nil, err
+	}
+	idx, _, err := d.findOperationRecord(command.date, command.id)
+	if err != nil {
+		return nil, err
+	}
+	// a month is changed and marked as modified before the next month is fetched, so it is saved instead of
+	// being lost when the fetch evicts it
+	newIdx, newRecord, err := d.getOrCreateRecord(op.Date)
+	if err != nil {
+		return nil, err
+	}
+	if newIdx == idx {
+		op.Id = command.id
+		err = newRecord.ModifyOperation(op)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// operation moves to another month and gets new id there
+		newRecord.AddOperation(op)
+		d.data.MarkAsModified(newIdx)
+		var record *entities.FinanceRecord
+		_, record, err = d.findOperationRecord(command.date, command.id)
+		if err != nil {
+			return nil, err
+		}
+		err = record.DeleteOperation(command.id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	d.data.MarkAsModified(idx)
+	err = d.persist(min(command.date, op.Date), max(command.date, op.Date), []entities.FinanceOperation{op})
+	if err != nil {
+		return nil, err
+	}
+	return d.getOpsAndChanges(op.Date)
 }
 
 func (d *dB) deleteOperation(command *deleteOperationCommand) ([]byte, error) {
-	return nil, errors.New("not implemented")
+	idx, record, err := d.findOperationRecord(command.date, command.id)
+	if err != nil {
+		return nil, err
+	}
+	err = record.DeleteOperation(command.id)
+	if err != nil {
+		return nil, err
+	}
+	d.data.MarkAsModified(idx)
+	err = d.persist(command.date, command.date, nil)
+	if err != nil {
+		return nil, err
+	}
+	return d.getOpsAndChanges(command.date)
 }
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"HomeAccountingDB/src/entities"
+	"TimeSeriesData/core"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func newTestDB(t *testing.T) *dB {
+	return newTestDBWithOptions(t)
+}
+
+func newTestDBWithOptions(t *testing.T, options ...core.TimeSeriesDataOption[entities.FinanceRecord]) *dB {
+	folder := t.TempDir()
+	s := settings{MinYear: 2012, MinMonth: 6, TimeSeriesDataCapacity: 1000, DataFolderPath: folder}
+	configuration := newBinaryDBConfiguration(nil)
+	accounts := []entities.Account{
+		{Id: 1, Name: "cash", CashAccount: -1, Currency: "UAH"},
+		{Id: 2, Name: "card", CashAccount: 1, Currency: "UAH"},
+		{Id: 3, Name: "closed", CashAccount: 1, Currency: "UAH", ActiveTo: 20190101},
+	}
+	categories := []entities.Category{{Id: 1, Name: "food"}}
+	subcategories := []entities.Subcategory{
+		{Id: 1, Name: "salary", OperationCodeId: entities.Incm, Code: entities.None, CategoryId: 1},
+		{Id: 2, Name: "shop", OperationCodeId: entities.Expn, Code: entities.None, CategoryId: 1},
+		{Id: 3, Name: "transfer", OperationCodeId: entities.Spcl, Code: entities.Trfr, CategoryId: 1,
+			RequiredProperties: []entities.FinOpPropertyCode{entities.Seca}},
+		{Id: 4, Name: "fuel", OperationCodeId: entities.Expn, Code: entities.Fuel, CategoryId: 1,
+			RequiredProperties: []entities.FinOpPropertyCode{entities.Amou, entities.Dist}},
+	}
+	db := &dB{
+		dataFolderPath: folder,
+		configuration:  configuration,
+		accounts:       core.NewDictionaryData[entities.Account](getAccountsFileName(folder), "account", accounts),
+		categories:     core.NewDictionaryData[entities.Category](getCategoriesFileName(folder), "category", categories),
+		subcategories: core.NewDictionaryData[entities.Subcategory](getSubcategoriesFileName(folder), "subcategory",
+			subcategories),
+		data: core.NewTimeSeriesData[entities.FinanceRecord](folder, configuration.GetMainDataSource(),
+			s.TimeSeriesDataCapacity, func(date int) int {
+				return indexCalculator(date, s.MinYear, s.MinMonth)
+			}, func(date int) int {
+				return date / 100
+			}, 1000000, append(configuration.GetTimeSeriesDataOptions(), options...)...),
+		hints: make(dbHints),
+	}
+	return db
+}
+
+func getBalance(t *testing.T, db *dB, date int, accountId int) int {
+	_, v, err := db.data.Get(date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := v.BuildOpsAndChanges(date, db.accounts, db.subcategories, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	change, ok := result.Changes[accountId]
+	if !ok {
+		return 0
+	}
+	return change.GetEndSumma()
+}
+
+func TestAddOperation(t *testing.T) {
+	db := newTestDB(t)
+	_, err := db.addOperation(&addOperationCommand{date: 20200110, subcategory: 1, account: 2, summa: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.addOperation(&addOperationCommand{date: 20200315, subcategory: 2, account: 2, summa: "10.5+2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := getBalance(t, db, 20200315, 2); balance != 8750 {
+		t.Fatalf("wrong balance %v", balance)
+	}
+	// back dated operation should update totals of all later months
+	_, err = db.addOperation(&addOperationCommand{date: 20200205, subcategory: 1, account: 2, summa: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := getBalance(t, db, 20200315, 2); balance != 8850 {
+		t.Fatalf("wrong balance %v", balance)
+	}
+	_, err = db.addOperation(&addOperationCommand{date: 20200205, subcategory: 99, account: 2, summa: "1"})
+	if err == nil {
+		t.Fatal("invalid subcategory should be rejected")
+	}
+	_, err = db.addOperation(&addOperationCommand{date: 20200205, subcategory: 1, account: 5, summa: "1"})
+	if err == nil {
+		t.Fatal("invalid account should be rejected")
+	}
+}
+
+func TestModifyAndDeleteOperation(t *testing.T) {
+	db := newTestDB(t)
+	for i := 0; i < 2; i++ {
+		_, err := db.addOperation(&addOperationCommand{date: 20200110, subcategory: 2, account: 2, summa: "10"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := db.addOperation(&addOperationCommand{date: 20200301, subcategory: 1, account: 2, summa: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// second operation of the same day, subcategory and account
+	_, err = db.modifyOperation(&modifyOperationCommand{date: 20200110, id: 2,
+		operation: addOperationCommand{date: 20200110, subcategory: 2, account: 2, summa: "15"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := getBalance(t, db, 20200301, 2); balance != 7500 {
+		t.Fatalf("wrong balance %v", balance)
+	}
+	// move operation to another month
+	_, err = db.modifyOperation(&modifyOperationCommand{date: 20200110, id: 1,
+		operation: addOperationCommand{date: 20200302, subcategory: 2, account: 2, summa: "10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := getBalance(t, db, 20200110, 2); balance != -1500 {
+		t.Fatalf("wrong balance %v", balance)
+	}
+	if balance := getBalance(t, db, 20200302, 2); balance != 7500 {
+		t.Fatalf("wrong balance %v", balance)
+	}
+	_, err = db.deleteOperation(&deleteOperationCommand{date: 20200110, id: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := getBalance(t, db, 20200302, 2); balance != 9000 {
+		t.Fatalf("wrong balance %v", balance)
+	}
+	_, err = db.deleteOperation(&deleteOperationCommand{date: 20200110, id: 2})
+	if err == nil {
+		t.Fatal("deleted operation should not be found")
+	}
+}
+
+func TestModifyOperationWithOneMonthBudget(t *testing.T) {
+	// only one month fits into memory, so fetching a month evicts the previous one
+	db := newTestDBWithOptions(t, core.WithMaxActiveBytes[entities.FinanceRecord](1))
+	for _, date := range []int{20200110, 20200120, 20200201, 20200301} {
+		_, err := db.addOperation(&addOperationCommand{date: date, subcategory: 1, account: 2, summa: "10"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// move operation to another month
+	_, err := db.modifyOperation(&modifyOperationCommand{date: 20200110, id: 1,
+		operation: addOperationCommand{date: 20200205, subcategory: 1, account: 2, summa: "20"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.modifyOperation(&modifyOperationCommand{date: 20200301, id: 1,
+		operation: addOperationCommand{date: 20200301, subcategory: 1, account: 2, summa: "30"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.deleteOperation(&deleteOperationCommand{date: 20200120, id: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		date    int
+		balance int
+	}{
+		{20200131, 0},
+		{20200229, 3000},
+		{20200331, 6000},
+	} {
+		if balance := getBalance(t, db, test.date, 2); balance != test.balance {
+			t.Fatalf("wrong balance %v for %v", balance, test.date)
+		}
+	}
+	if db.data.Stats().Evictions == 0 {
+		t.Fatal("months should be evicted")
+	}
+}
+
+func TestUpdateTotalsSavesAffectedMonthsOnly(t *testing.T) {
+	db := newTestDB(t)
+	for _, date := range []int{20200101, 20200201, 20200301, 20200401} {
+		_, err := db.addOperation(&addOperationCommand{date: date, subcategory: 1, account: 2, summa: "10"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	removeFiles := func() {
+		for _, date := range []int{202001, 202002, 202003, 202004} {
+			err := os.Remove(db.dataFolderPath + "/" + strconv.Itoa(date) + ".bin")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	checkFiles := func(shouldExist ...bool) {
+		for i, date := range []int{202001, 202002, 202003, 202004} {
+			_, err := os.Stat(db.dataFolderPath + "/" + strconv.Itoa(date) + ".bin")
+			if (err == nil) != shouldExist[i] {
+				t.Fatalf("unexpected %v file state", date)
+			}
+		}
+	}
+	removeFiles()
+	// totals are not changed
+	_, err := db.modifyOperation(&modifyOperationCommand{date: 20200201, id: 1,
+		operation: addOperationCommand{date: 20200215, subcategory: 1, account: 2, summa: "10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(false, true, false, false)
+	_, err = db.deleteOperation(&deleteOperationCommand{date: 20200215, id: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(false, true, true, true)
+	if balance := getBalance(t, db, 20200401, 2); balance != 3000 {
+		t.Fatalf("wrong balance %v", balance)
+	}
+}
+
+func TestOperationBeforeMinDate(t *testing.T) {
+	db := newTestDB(t)
+	_, err := db.addOperation(&addOperationCommand{date: 20200110, subcategory: 1, account: 2, summa: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.addOperation(&addOperationCommand{date: 20110510, subcategory: 1, account: 2, summa: "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := getBalance(t, db, 20200110, 2); balance != 10500 {
+		t.Fatalf("wrong balance %v", balance)
+	}
+}
+
+func TestStatsCommand(t *testing.T) {
+	db := newTestDB(t)
+	_, err := db.addOperation(&addOperationCommand{date: 20200110, subcategory: 1, account: 2, summa: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := decodeRequest([]byte{16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := cmd.Execute(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 80 {
+		t.Fatalf("unexpected stats length %v", len(result))
+	}
+	if db.data.Stats().WriteBacks != 1 {
+		t.Fatal("saved month should be counted")
+	}
+}
+
+func TestShutdownSavesModifiedMonths(t *testing.T) {
+	db := newTestDB(t)
+	db.flusherEnabled = true
+	_, err := db.addOperation(&addOperationCommand{date: 20200110, subcategory: 1, account: 2, summa: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := db.dataFolderPath + "/202001.bin"
+	if _, err = os.Stat(fileName); !os.IsNotExist(err) {
+		t.Fatal("modified month should not be saved before shutdown")
+	}
+	userData := tcpServerData{db: db}
+	err = userData.shutdown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(fileName); err != nil {
+		t.Fatal("modified month should be saved on shutdown")
+	}
+}
+
+func TestVerifyDB(t *testing.T) {
+	db := newTestDB(t)
+	_, err := db.addOperation(&addOperationCommand{date: 20200110, subcategory: 1, account: 2, summa: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder := t.TempDir()
+	err = os.Mkdir(getMainDataFolderPath(folder), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.saveTo(folder, db.configuration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := settings{MinYear: 2012, MinMonth: 6, TimeSeriesDataCapacity: 1000, DataFolderPath: folder}
+	errs, err := verifyDB(s, db.configuration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	for _, fileName := range []string{getAccountsFileName(folder) + ".bin", getMainDataFolderPath(folder) + "/202001.bin"} {
+		f, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.Write([]byte{0})
+		_ = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	errs, err = verifyDB(s, db.configuration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	for _, e := range errs {
+		var decodeErr *core.DecodeError
+		if !errors.As(e, &decodeErr) || decodeErr.FileName != e.FileName ||
+			decodeErr.Err.Error() != "non zero buffer length" {
+			t.Fatalf("unexpected error %v", e)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"HomeAccountingDB/src/entities"
+	"strings"
+)
+
+func (d *dB) saveAccounts() error {
+	return d.accounts.SaveToFile(d.configuration.GetSaver(), getAccountsFileName(d.dataFolderPath),
+		entities.SaveAccountByIndex)
+}
+
+func (d *dB) saveCategories() error {
+	return d.categories.SaveToFile(d.configuration.GetSaver(), getCategoriesFileName(d.dataFolderPath),
+		entities.SaveCategoryByIndex)
+}
+
+func (d *dB) saveSubcategories() error {
+	return d.subcategories.SaveToFile(d.configuration.GetSaver(), getSubcategoriesFileName(d.dataFolderPath),
+		entities.SaveSubcategoryByIndex)
+}
+
+func validateName(name string) error {
+	if len(strings.TrimSpace(name)) == 0 {
+		return newDBError(errorInvalidName, "name should not be empty")
+	}
+	return nil
+}
+
+// isUsedByOperations checks all stored operations with predicate
+func (d *dB) isUsedByOperations(predicate func(op *entities.FinanceOperation) bool) (bool, error) {
+	i, err := d.data.Iterator(0, 99999999)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range i.All() {
+		if v.ContainsOperation(predicate) {
+			return true, nil
+		}
+	}
+	return false, i.Err()
+}
+
+func (d *dB) addAccount(command *addAccountCommand) ([]byte, error) {
+	err := validateName(command.name)
+	if err != nil {
+		return nil, err
+	}
+	if len(command.currency) == 0 {
+		return nil, newDBError(errorInvalidCode, "currency code should not be empty")
+	}
+	var cashAccount entities.Int = -1
+	if !command.isCash {
+		cash := d.accounts.Find(func(a entities.Account) bool {
+			return a.CashAccount == -1 && a.Currency == command.currency
+		})
+		if cash != nil {
+			cashAccount = entities.Int(cash.Id)
+		} else {
+			cashAccount = 0
+		}
+	}
+	d.accounts.Add(func(id int) entities.Account {
+		return entities.Account{Id: id, Name: command.name, CashAccount: cashAccount, Currency: command.currency}
+	})
+	err = d.saveAccounts()
+	if err != nil {
+		return nil, err
+	}
+	return d.getDicts()
+}
+
+func (d *dB) closeAccount(command *closeAccountCommand) ([]byte, error) {
+	account, err := d.accounts.Get(command.id)
+	if err != nil {
+		return nil, newDBError(errorInvalidAccount, "%v", err.Error())
+	}
+	// zero date reopens the account
+	if command.activeTo != 0 {
+		err = validateDate(command.activeTo)
+		if err != nil {
+			return nil, err
+		}
+	}
+	account.ActiveTo = entities.Date(command.activeTo)
+	err = d.accounts.Update(*account)
+	if err != nil {
+		return nil, err
+	}
+	err = d.saveAccounts()
+	if err != nil {
+		return nil, err
+	}
+	return d.getDicts()
+}
+
+func (d *dB) addCategory(command *addCategoryCommand) ([]byte, error) {
+	err := validateName(command.name)
+	if err != nil {
+		return nil, err
+	}
+	d.categories.Add(func(id int) entities.Category {
+		return entities.Category{Id: id, Name: command.name}
+	})
+	err = d.saveCategories()
+	if err != nil {
+		return nil, err
+	}
+	return d.getDicts()
+}
+
+func validateSubcategoryCodes(code entities.SubcategoryCode, operationCode entities.SubcategoryOperationCode) error {
+	if code < entities.Comb || code > entities.None {
+		return newDBError(errorInvalidCode, "invalid subcategory code %v", code)
+	}
+	switch operationCode {
+	case entities.Incm, entities.Expn:
+		return nil
+	case entities.Spcl:
+		switch code {
+		case entities.Incc, entities.Expc, entities.Exch, entities.Trfr:
+			return nil
+		default:
+			return newDBError(errorInvalidCode, "invalid subcategory code %v for special operation", code)
+		}
+	default:
+		return newDBError(errorInvalidCode, "invalid subcategory operation code %v", operationCode)
+	}
+}
+
+func (d *dB) addSubcategory(command *addSubcategoryCommand) ([]byte, error) {
+	err := validateName(command.name)
+	if err != nil {
+		return nil, err
+	}
+	_, err = d.categories.Get(command.categoryId)
+	if err != nil {
+		return nil, newDBError(errorInvalidCategory, "%v", err.Error())
+	}
+	code := entities.SubcategoryCode(command.code)
+	operationCode := entities.SubcategoryOperationCode(command.operationCode)
+	err = validateSubcategoryCodes(code, operationCode)
+	if err != nil {
+		return nil, err
+	}
+	// required properties are defined per subcategory code
+	var requiredProperties []entities.FinOpPropertyCode
+	if code != entities.None {
+		s := d.subcategories.Find(func(s entities.Subcategory) bool { return s.Code == code })
+		if s != nil {
+			requiredProperties = s.RequiredProperties
+		}
+	}
+	d.subcategories.Add(func(id int) entities.Subcategory {
+		return entities.Subcategory{Id: id, Code: code, Name: command.name, OperationCodeId: operationCode,
+			CategoryId: command.categoryId, RequiredProperties: requiredProperties}
+	})
+	err = d.saveSubcategories()
+	if err != nil {
+		return nil, err
+	}
+	return d.getDicts()
+}
+
+func (d *dB) renameDictionaryItem(command *renameCommand) ([]byte, error) {
+	err := validateName(command.name)
+	if err != nil {
+		return nil, err
+	}
+	switch command.dictionary {
+	case accountsDictionary:
+		err = d.renameAccount(command.id, command.name)
+	case categoriesDictionary:
+		err = d.renameCategory(command.id, command.name)
+	default:
+		err = d.renameSubcategory(command.id, command.name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.getDicts()
+}
+
+func (d *dB) renameAccount(id int, name string) error {
+	account, err := d.accounts.Get(id)
+	if err != nil {
+		return newDBError(errorInvalidAccount, "%v", err.Error())
+	}
+	account.Name = name
+	err = d.accounts.Update(*account)
+	if err != nil {
+		return err
+	}
+	return d.saveAccounts()
+}
+
+func (d *dB) renameCategory(id int, name string) error {
+	category, err := d.categories.Get(id)
+	if err != nil {
+		return newDBError(errorInvalidCategory, "%v", err.Error())
+	}
+	category.Name = name
+	err = d.categories.Update(*category)
+	if err != nil {
+		return err
+	}
+	return d.saveCategories()
+}
+
+func (d *dB) renameSubcategory(id int, name string) error {
+	subcategory, err := d.subcategories.Get(id)
+	if err != nil {
+		return newDBError(errorInvalidSubcategory, "%v", err.Error())
+	}
+	subcategory.Name = name
+	err = d.subcategories.Update(*subcategory)
+	if err != nil {
+		return err
+	}
+	return d.saveSubcategories()
+}
+
+func (d *dB) deleteDictionaryItem(command *deleteCommand) ([]byte, error) {
+	var err error
+	switch command.dictionary {
+	case accountsDictionary:
+		err = d.deleteAccount(command.id)
+	case categoriesDictionary:
+		err = d.deleteCategory(command.id)
+	default:
+		err = d.deleteSubcategory(command.id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.getDicts()
+}
+
+func (d *dB) deleteAccount(id int) error {
+	account, err := d.accounts.Get(id)
+	if err != nil {
+		return newDBError(errorInvalidAccount, "%v", err.Error())
+	}
+	if d.accounts.Find(func(a entities.Account) bool { return int(a.CashAccount) == id }) != nil {
+		return newDBError(errorItemIsInUse, "account %v is used as cash account", account.Name)
+	}
+	used, err := d.isUsedByOperations(func(op *entities.FinanceOperation) bool {
+		if op.AccountId == id {
+			return true
+		}
+		prop := op.GetProperty(entities.Seca)
+		return prop != nil && prop.NumericValue != nil && *prop.NumericValue == id
+	})
+	if err != nil {
+		return err
+	}
+	if used {
+		return newDBError(errorItemIsInUse, "account %v is used by operations", account.Name)
+	}
+	err = d.accounts.Delete(id)
+	if err != nil {
+		return err
+	}
+	return d.saveAccounts()
+}
+
+func (d *dB) deleteCategory(id int) error {
+	category, err := d.categories.Get(id)
+	if err != nil {
+		return newDBError(errorInvalidCategory, "%v", err.Error())
+	}
+	if d.subcategories.Find(func(s entities.Subcategory) bool { return s.CategoryId == id }) != nil {
+		return newDBError(errorItemIsInUse, "category %v has subcategories", category.Name)
+	}
+	err = d.categories.Delete(id)
+	if err != nil {
+		return err
+	}
+	return d.saveCategories()
+}
+
+func (d *dB) deleteSubcategory(id int) error {
+	subcategory, err := d.subcategories.Get(id)
+	if err != nil {
+		return newDBError(errorInvalidSubcategory, "%v", err.Error())
+	}
+	used, err := d.isUsedByOperations(func(op *entities.FinanceOperation) bool { return op.SubcategoryId == id })
+	if err != nil {
+		return err
+	}
+	if used {
+		return newDBError(errorItemIsInUse, "subcategory %v is used by operations", subcategory.Name)
+	}
+	err = d.subcategories.Delete(id)
+	if err != nil {
+		return err
+	}
+	return d.saveSubcategories()
+}
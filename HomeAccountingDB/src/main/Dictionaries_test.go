@@ -0,0 +1,113 @@
+package main
+
+import (
+	"HomeAccountingDB/src/entities"
+	"reflect"
+	"testing"
+)
+
+func TestAccountCommands(t *testing.T) {
+	db := newTestDB(t)
+	_, err := db.addAccount(&addAccountCommand{name: "card2", currency: "UAH"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := db.accounts.Get(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.CashAccount != 1 {
+		t.Fatal("cash account should be assigned")
+	}
+	_, err = db.renameDictionaryItem(&renameCommand{accountsDictionary, 4, "renamed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.closeAccount(&closeAccountCommand{4, 20200101})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts, err := db.configuration.GetAccounts(getAccountsFileName(db.dataFolderPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved *entities.Account
+	for _, a := range accounts {
+		if a.Id == 4 {
+			saved = &a
+		}
+	}
+	if saved == nil || saved.Name != "renamed" || saved.ActiveTo != 20200101 {
+		t.Fatal("account should be saved")
+	}
+	_, err = db.addOperation(&addOperationCommand{date: 20200101, subcategory: 1, account: 2, summa: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.deleteDictionaryItem(&deleteCommand{accountsDictionary, 2})
+	checkErrorCode(t, err, errorItemIsInUse)
+	_, err = db.deleteDictionaryItem(&deleteCommand{accountsDictionary, 1})
+	checkErrorCode(t, err, errorItemIsInUse)
+	_, err = db.deleteDictionaryItem(&deleteCommand{accountsDictionary, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.deleteDictionaryItem(&deleteCommand{accountsDictionary, 4})
+	checkErrorCode(t, err, errorInvalidAccount)
+}
+
+func TestCategoryCommands(t *testing.T) {
+	db := newTestDB(t)
+	_, err := db.addCategory(&addCategoryCommand{""})
+	checkErrorCode(t, err, errorInvalidName)
+	_, err = db.addCategory(&addCategoryCommand{"category2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.addSubcategory(&addSubcategoryCommand{2, "fuel2", int(entities.Fuel), int(entities.Spcl)})
+	checkErrorCode(t, err, errorInvalidCode)
+	_, err = db.addSubcategory(&addSubcategoryCommand{3, "fuel2", int(entities.Fuel), int(entities.Expn)})
+	checkErrorCode(t, err, errorInvalidCategory)
+	_, err = db.addSubcategory(&addSubcategoryCommand{2, "fuel2", int(entities.Fuel), int(entities.Expn)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subcategory, err := db.subcategories.Get(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(subcategory.RequiredProperties, []entities.FinOpPropertyCode{entities.Amou, entities.Dist}) {
+		t.Fatal("required properties should be copied")
+	}
+	_, err = db.renameDictionaryItem(&renameCommand{categoriesDictionary, 2, "renamed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.renameDictionaryItem(&renameCommand{subcategoriesDictionary, 5, "renamed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.deleteDictionaryItem(&deleteCommand{categoriesDictionary, 2})
+	checkErrorCode(t, err, errorItemIsInUse)
+	_, err = db.addOperation(&addOperationCommand{date: 20200101, subcategory: 2, account: 2, summa: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.deleteDictionaryItem(&deleteCommand{subcategoriesDictionary, 2})
+	checkErrorCode(t, err, errorItemIsInUse)
+	_, err = db.deleteDictionaryItem(&deleteCommand{subcategoriesDictionary, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.deleteDictionaryItem(&deleteCommand{categoriesDictionary, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subcategories, err := db.configuration.GetSubcategories(getSubcategoriesFileName(db.dataFolderPath), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subcategories) != 4 {
+		t.Fatal("subcategories should be saved")
+	}
+}
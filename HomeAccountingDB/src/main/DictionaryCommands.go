@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+type dictionaryType int
+
+const (
+	accountsDictionary      dictionaryType = 0
+	categoriesDictionary    dictionaryType = iota
+	subcategoriesDictionary dictionaryType = iota
+)
+
+func (t dictionaryType) String() string {
+	switch t {
+	case accountsDictionary:
+		return "Account"
+	case categoriesDictionary:
+		return "Category"
+	default:
+		return "Subcategory"
+	}
+}
+
+type addAccountCommand struct {
+	name     string
+	currency string
+	isCash   bool
+}
+
+func newAddAccountCommand(buffer *bytes.Buffer) (command, error) {
+	name, err := readString(buffer)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := readString(buffer)
+	if err != nil {
+		return nil, err
+	}
+	var isCash uint8
+	err = binary.Read(buffer, binary.LittleEndian, &isCash)
+	if err != nil {
+		return nil, err
+	}
+	if buffer.Len() != 0 {
+		return nil, errors.New("invalid addAccount command")
+	}
+	fmt.Printf("addAccount command, name=%v currency=%v isCash=%v\n", name, currency, isCash)
+	return &addAccountCommand{name, currency, isCash != 0}, nil
+}
+
+func (c *addAccountCommand) Execute(db *dB) ([]byte, error) {
+	return db.addAccount(c)
+}
+
+func (c *addAccountCommand) ReadOnlyLockRequired() bool {
+	return false
+}
+
+type closeAccountCommand struct {
+	id       int
+	activeTo int
+}
+
+func newCloseAccountCommand(buffer *bytes.Buffer) (command, error) {
+	if buffer.Len() != 8 {
+		return nil, errors.New("invalid closeAccount command")
+	}
+	var id uint32
+	err := binary.Read(buffer, binary.LittleEndian, &id)
+	if err != nil {
+		return nil, err
+	}
+	var activeTo uint32
+	err = binary.Read(buffer, binary.LittleEndian, &activeTo)
+	fmt.Printf("closeAccount command, id=%v activeTo=%v\n", id, activeTo)
+	return &closeAccountCommand{int(id), int(activeTo)}, err
+}
+
+func (c *closeAccountCommand) Execute(db *dB) ([]byte, error) {
+	return db.closeAccount(c)
+}
+
+func (c *closeAccountCommand) ReadOnlyLockRequired() bool {
+	return false
+}
+
+type addCategoryCommand struct {
+	name string
+}
+
+func newAddCategoryCommand(buffer *bytes.Buffer) (command, error) {
+	name, err := readString(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if buffer.Len() != 0 {
+		return nil, errors.New("invalid addCategory command")
+	}
+	fmt.Printf("addCategory command, name=%v\n", name)
+	return &addCategoryCommand{name}, nil
+}
+
+func (c *addCategoryCommand) Execute(db *dB) ([]byte, error) {
+	return db.addCategory(c)
+}
+
+func (c *addCategoryCommand) ReadOnlyLockRequired() bool {
+	return false
+}
+
+type addSubcategoryCommand struct {
+	categoryId    int
+	name          string
+	code          int
+	operationCode int
+}
+
+func newAddSubcategoryCommand(buffer *bytes.Buffer) (command, error) {
+	var categoryId uint32
+	err := binary.Read(buffer, binary.LittleEndian, &categoryId)
+	if err != nil {
+		return nil, err
+	}
+	name, err := readString(buffer)
+	if err != nil {
+		return nil, err
+	}
+	var code uint8
+	err = binary.Read(buffer, binary.LittleEndian, &code)
+	if err != nil {
+		return nil, err
+	}
+	var operationCode uint8
+	err = binary.Read(buffer, binary.LittleEndian, &operationCode)
+	if err != nil {
+		return nil, err
+	}
+	if buffer.Len() != 0 {
+		return nil, errors.New("invalid addSubcategory command")
+	}
+	c := addSubcategoryCommand{int(categoryId), name, int(code), int(operationCode)}
+	fmt.Printf("addSubcategory command %v\n", c)
+	return &c, nil
+}
+
+func (c *addSubcategoryCommand) Execute(db *dB) ([]byte, error) {
+	return db.addSubcategory(c)
+}
+
+func (c *addSubcategoryCommand) ReadOnlyLockRequired() bool {
+	return false
+}
+
+type renameCommand struct {
+	dictionary dictionaryType
+	id         int
+	name       string
+}
+
+func newRenameCommand(buffer *bytes.Buffer, dictionary dictionaryType) (command, error) {
+	var id uint32
+	err := binary.Read(buffer, binary.LittleEndian, &id)
+	if err != nil {
+		return nil, err
+	}
+	name, err := readString(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if buffer.Len() != 0 {
+		return nil, fmt.Errorf("invalid rename%v command", dictionary)
+	}
+	fmt.Printf("rename%v command, id=%v name=%v\n", dictionary, id, name)
+	return &renameCommand{dictionary, int(id), name}, nil
+}
+
+func (c *renameCommand) Execute(db *dB) ([]byte, error) {
+	return db.renameDictionaryItem(c)
+}
+
+func (c *renameCommand) ReadOnlyLockRequired() bool {
+	return false
+}
+
+type deleteCommand struct {
+	dictionary dictionaryType
+	id         int
+}
+
+func newDeleteCommand(buffer *bytes.Buffer, dictionary dictionaryType) (command, error) {
+	if buffer.Len() != 4 {
+		return nil, fmt.Errorf("invalid delete%v command", dictionary)
+	}
+	var id uint32
+	err := binary.Read(buffer, binary.LittleEndian, &id)
+	fmt.Printf("delete%v command, id=%v\n", dictionary, id)
+	return &deleteCommand{dictionary, int(id)}, err
+}
+
+func (c *deleteCommand) Execute(db *dB) ([]byte, error) {
+	return db.deleteDictionaryItem(c)
+}
+
+func (c *deleteCommand) ReadOnlyLockRequired() bool {
+	return false
+}
@@ -0,0 +1,39 @@
+package main
+
+import "fmt"
+
+type errorCode uint16
+
+// error codes returned to the client in ERROR_CODE responses, values are a part of the protocol
+const (
+	errorInvalidDate          errorCode = 1
+	errorInvalidAccount       errorCode = 2
+	errorInactiveAccount      errorCode = 3
+	errorInvalidSubcategory   errorCode = 4
+	errorInvalidSumma         errorCode = 5
+	errorInvalidAmount        errorCode = 6
+	errorMissingProperty      errorCode = 7
+	errorInvalidPropertyValue errorCode = 8
+	errorOperationNotFound    errorCode = 9
+	errorInvalidName          errorCode = 10
+	errorInvalidCategory      errorCode = 11
+	errorInvalidCode          errorCode = 12
+	errorItemIsInUse          errorCode = 13
+)
+
+type dbError struct {
+	code    errorCode
+	message string
+}
+
+func newDBError(code errorCode, format string, a ...any) error {
+	return &dbError{code: code, message: fmt.Sprintf(format, a...)}
+}
+
+func (e *dbError) Error() string {
+	return e.message
+}
+
+func (e *dbError) ErrorCode() uint16 {
+	return uint16(e.code)
+}
@@ -0,0 +1,14 @@
+package main
+
+import "testing"
+
+func TestErrorCodes(t *testing.T) {
+	codes := []errorCode{errorInvalidDate, errorInvalidAccount, errorInactiveAccount, errorInvalidSubcategory,
+		errorInvalidSumma, errorInvalidAmount, errorMissingProperty, errorInvalidPropertyValue, errorOperationNotFound,
+		errorInvalidName, errorInvalidCategory, errorInvalidCode, errorItemIsInUse}
+	for i, code := range codes {
+		if code != errorCode(i+1) {
+			t.Fatalf("unexpected value %v of error code %v", code, i+1)
+		}
+	}
+}
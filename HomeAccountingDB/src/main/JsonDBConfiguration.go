@@ -22,6 +22,7 @@ func (s jsonDatedSource) Load(files []core.FileWithDate) (*entities.FinanceRecor
 		}
 		for idx := range ops {
 			ops[idx].Date = f.Date
+			ops[idx].Id = len(operations) + idx + 1
 		}
 		operations = append(operations, ops...)
 	}
@@ -36,6 +37,14 @@ func (s jsonDatedSource) Save(date int, data *entities.FinanceRecord, dataFolder
 	return errors.New("not implemented")
 }
 
+func (s jsonDatedSource) Delete(date int, dataFolderPath string) error {
+	return errors.New("not implemented")
+}
+
+func (s jsonDatedSource) EstimateSize(data *entities.FinanceRecord) int {
+	return data.EstimateSize()
+}
+
 type jsonDBConfiguration struct{}
 
 func (c jsonDBConfiguration) GetHints(fileName string) (dbHints, error) {
@@ -108,3 +117,7 @@ func (c jsonDBConfiguration) GetSubcategories(fileName, mapFileName string) ([]e
 func (c jsonDBConfiguration) GetMainDataSource() core.DatedSource[entities.FinanceRecord] {
 	return jsonDatedSource{}
 }
+
+func (c jsonDBConfiguration) GetTimeSeriesDataOptions() []core.TimeSeriesDataOption[entities.FinanceRecord] {
+	return nil
+}
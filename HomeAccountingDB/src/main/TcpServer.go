@@ -1,9 +1,11 @@
 package main
 
 import (
+	"TimeSeriesData/core"
 	"TimeSeriesData/crypto"
 	"bytes"
 	"errors"
+	"fmt"
 	"reflect"
 	"sync"
 )
@@ -46,7 +48,7 @@ func (d *tcpServerData) handle(request []byte) ([]byte, error, bool) {
 				return nil, err, true
 			}
 			c := newBinaryDBConfiguration(aes)
-			d.db, err = initDatabase(d.s, c)
+			d.db, err = initDatabase(d.s, c, d.lock.RLocker())
 			if err != nil {
 				d.lock.Unlock()
 				// terminate program
@@ -67,9 +69,38 @@ func (d *tcpServerData) handle(request []byte) ([]byte, error, bool) {
 	return data, err, false
 }
 
+// shutdown saves modified months and closes the database
+func (d *tcpServerData) shutdown() error {
+	d.lock.Lock()
+	db := d.db
+	var err error
+	if db != nil {
+		fmt.Println("Saving modified data...")
+		err = db.data.Save()
+	}
+	d.lock.Unlock()
+	if db == nil {
+		return nil
+	}
+	// the flusher acquires the lock, so it is closed without the lock
+	closeErr := db.data.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
 func decodeRequest(request []byte) (command, error) {
 	buffer := bytes.NewBuffer(request[1:])
-	switch request[0] {
+	cmd, err := newCommand(request[0], buffer)
+	if err != nil {
+		return nil, &core.DecodeError{Offset: int64(len(request) - buffer.Len()), Err: err}
+	}
+	return cmd, nil
+}
+
+func newCommand(code byte, buffer *bytes.Buffer) (command, error) {
+	switch code {
 	case 0: // DICTS request
 		return newDictsCommand(buffer)
 	case 1: // DICTS request
@@ -77,11 +108,33 @@ func decodeRequest(request []byte) (command, error) {
 	case 2: // DICTS request
 		return newOpsRangeCommand(buffer)
 	case 3: // DICTS request
-		return newAddOperationCommand(buffer, "addOperation")
+		return newAddOperationCommand(buffer)
 	case 4: // DICTS request
 		return newModifyOperationCommand(buffer)
 	case 5: // DICTS request
 		return newDeleteOperationCommand(buffer)
+	case 6: // ADD ACCOUNT request
+		return newAddAccountCommand(buffer)
+	case 7: // RENAME ACCOUNT request
+		return newRenameCommand(buffer, accountsDictionary)
+	case 8: // CLOSE ACCOUNT request
+		return newCloseAccountCommand(buffer)
+	case 9: // DELETE ACCOUNT request
+		return newDeleteCommand(buffer, accountsDictionary)
+	case 10: // ADD CATEGORY request
+		return newAddCategoryCommand(buffer)
+	case 11: // RENAME CATEGORY request
+		return newRenameCommand(buffer, categoriesDictionary)
+	case 12: // DELETE CATEGORY request
+		return newDeleteCommand(buffer, categoriesDictionary)
+	case 13: // ADD SUBCATEGORY request
+		return newAddSubcategoryCommand(buffer)
+	case 14: // RENAME SUBCATEGORY request
+		return newRenameCommand(buffer, subcategoriesDictionary)
+	case 15: // DELETE SUBCATEGORY request
+		return newDeleteCommand(buffer, subcategoriesDictionary)
+	case 16: // STATS request
+		return newStatsCommand(buffer)
 	default:
 		return nil, errors.New("unknown command")
 	}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"TimeSeriesData/core"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func addOperationRequest() []byte {
+	request := []byte{3}
+	for _, v := range []uint32{20200101, 1, 2} {
+		request = binary.LittleEndian.AppendUint32(request, v)
+	}
+	request = append(request, 2, 0, '1', '0', 0, 0)
+	// one property
+	request = append(request, 1, 0)
+	request = binary.LittleEndian.AppendUint64(request, 5)
+	return append(request, 0, 0, 0, 0, 0, 0, 1)
+}
+
+func TestDecodeRequestErrors(t *testing.T) {
+	request := addOperationRequest()
+	_, err := decodeRequest(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// property count over the limit
+	request[19] = 17
+	_, err = decodeRequest(request)
+	var decodeErr *core.DecodeError
+	if !errors.As(err, &decodeErr) || !errors.Is(err, core.ErrDecodeLimit) || decodeErr.Offset != 21 {
+		t.Fatalf("limit error expected: %v", err)
+	}
+	// string length exceeding the request
+	request[19] = 1
+	request[13] = 0xFF
+	_, err = decodeRequest(request)
+	if !errors.As(err, &decodeErr) || decodeErr.Offset != 15 {
+		t.Fatalf("length error expected: %v", err)
+	}
+}
+
+func FuzzDecodeRequest(f *testing.F) {
+	f.Add([]byte{0})
+	f.Add([]byte{7, 1, 0, 0, 0, 4, 0, 'n', 'a', 'm', 'e'})
+	f.Add([]byte{13, 1, 0, 0, 0, 4, 0, 'n', 'a', 'm', 'e', 1, 1})
+	f.Add(addOperationRequest())
+	f.Fuzz(func(t *testing.T, request []byte) {
+		if len(request) == 0 {
+			return
+		}
+		_, err := decodeRequest(request)
+		var decodeErr *core.DecodeError
+		if err != nil && !errors.As(err, &decodeErr) {
+			t.Fatalf("DecodeError expected: %v", err)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"HomeAccountingDB/src/entities"
+)
+
+func validateDate(date int) error {
+	if !entities.Date(date).IsValid() {
+		return newDBError(errorInvalidDate, "invalid date %v", date)
+	}
+	return nil
+}
+
+func (d *dB) validateAccount(accountId, date int) error {
+	account, err := d.accounts.Get(accountId)
+	if err != nil {
+		return newDBError(errorInvalidAccount, "%v", err.Error())
+	}
+	if !account.IsActive(date) {
+		return newDBError(errorInactiveAccount, "account %v is closed since %v", account.Name, account.ActiveTo)
+	}
+	return nil
+}
+
+func (d *dB) validateProperties(op *entities.FinanceOperation, subcategory *entities.Subcategory) error {
+	for _, prop := range op.FinOpProperties {
+		if !prop.HasValidValue() {
+			return newDBError(errorInvalidPropertyValue, "invalid property %v value", prop.PropertyCode)
+		}
+		if prop.PropertyCode == entities.Seca {
+			err := d.validateAccount(*prop.NumericValue, op.Date)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, code := range subcategory.RequiredProperties {
+		if op.GetProperty(code) == nil {
+			return newDBError(errorMissingProperty, "property %v is required for subcategory %v", code,
+				subcategory.Name)
+		}
+	}
+	return nil
+}
+
+func (d *dB) validateOperation(op *entities.FinanceOperation) error {
+	err := validateDate(op.Date)
+	if err != nil {
+		return err
+	}
+	err = d.validateAccount(op.AccountId, op.Date)
+	if err != nil {
+		return err
+	}
+	subcategory, err := d.subcategories.Get(op.SubcategoryId)
+	if err != nil {
+		return newDBError(errorInvalidSubcategory, "%v", err.Error())
+	}
+	return d.validateProperties(op, subcategory)
+}
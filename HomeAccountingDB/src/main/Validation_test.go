@@ -0,0 +1,58 @@
+package main
+
+import (
+	"HomeAccountingDB/src/entities"
+	"errors"
+	"testing"
+)
+
+func checkErrorCode(t *testing.T, err error, code errorCode) {
+	var e *dbError
+	if !errors.As(err, &e) {
+		t.Fatalf("dbError expected, got %v", err)
+	}
+	if e.code != code {
+		t.Fatalf("error code %v expected, got %v: %v", code, e.code, e.message)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	db := newTestDB(t)
+	secondAccount := 1
+	closedAccount := 3
+	distance := 100
+	network := "network"
+	_, err := db.addOperation(&addOperationCommand{date: 20201301, subcategory: 2, account: 2, summa: "1"})
+	checkErrorCode(t, err, errorInvalidDate)
+	_, err = db.addOperation(&addOperationCommand{date: 20200101, subcategory: 2, account: 2, summa: "1+"})
+	checkErrorCode(t, err, errorInvalidSumma)
+	_, err = db.addOperation(&addOperationCommand{date: 20200101, subcategory: 2, account: 3, summa: "1"})
+	checkErrorCode(t, err, errorInactiveAccount)
+	_, err = db.addOperation(&addOperationCommand{date: 20200101, subcategory: 3, account: 2, summa: "1"})
+	checkErrorCode(t, err, errorMissingProperty)
+	_, err = db.addOperation(&addOperationCommand{date: 20200101, subcategory: 3, account: 2, summa: "1",
+		properties: []entities.FinOpProperty{{NumericValue: &closedAccount, PropertyCode: entities.Seca}}})
+	checkErrorCode(t, err, errorInactiveAccount)
+	_, err = db.addOperation(&addOperationCommand{date: 20200101, subcategory: 3, account: 2, summa: "1",
+		properties: []entities.FinOpProperty{{StringValue: &network, PropertyCode: entities.Seca}}})
+	checkErrorCode(t, err, errorInvalidPropertyValue)
+	_, err = db.addOperation(&addOperationCommand{date: 20200101, subcategory: 3, account: 2, summa: "1",
+		properties: []entities.FinOpProperty{{NumericValue: &secondAccount, PropertyCode: entities.Seca}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.addOperation(&addOperationCommand{date: 20200101, subcategory: 4, account: 2, summa: "1", amount: "10",
+		properties: []entities.FinOpProperty{{NumericValue: &distance, PropertyCode: entities.Dist}}})
+	checkErrorCode(t, err, errorMissingProperty)
+	_, err = db.addOperation(&addOperationCommand{date: 20200101, subcategory: 4, account: 2, summa: "1",
+		properties: []entities.FinOpProperty{
+			{NumericValue: &distance, PropertyCode: entities.Dist},
+			{NumericValue: &distance, PropertyCode: entities.Amou},
+			{StringValue: &network, PropertyCode: entities.Netw},
+		}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.deleteOperation(&deleteOperationCommand{date: 20200101, id: 10})
+	checkErrorCode(t, err, errorOperationNotFound)
+}
@@ -9,12 +9,13 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 )
 
 func usage() {
-	fmt.Println("Usage: HomeAccountingDB2 config_file_name\n  test_json date\n  test date aes_key_file\n  migrate source_folder aes_key_file\n server")
+	fmt.Println("Usage: HomeAccountingDB2 config_file_name\n  test_json date\n  test date aes_key_file\n  migrate source_folder aes_key_file\n  stats aes_key_file\n  verify aes_key_file\n  upgrade aes_key_file\n server")
 }
 
 func main() {
@@ -46,12 +47,30 @@ func main() {
 		} else {
 			migrate(s, os.Args[3], os.Args[4])
 		}
+	case "stats":
+		if l != 4 {
+			usage()
+		} else {
+			printStats(s, os.Args[3])
+		}
 	case "server":
 		if l != 3 {
 			usage()
 		} else {
 			startServer(s)
 		}
+	case "verify":
+		if l != 4 {
+			usage()
+		} else {
+			verify(s, os.Args[3])
+		}
+	case "upgrade":
+		if l != 4 {
+			usage()
+		} else {
+			upgrade(s, os.Args[3])
+		}
 	default:
 		usage()
 	}
@@ -66,6 +85,9 @@ func startServer(s settings) {
 	if err != nil {
 		panic(err)
 	}
+	server.SetShutdownHandler(func(userData *tcpServerData) error {
+		return userData.shutdown()
+	})
 
 	//handle CTRL C
 	c := make(chan os.Signal, 1)
@@ -110,10 +132,10 @@ func buildDB(s settings, dbConfiguration dBConfiguration) *dB {
 	return db
 }
 
-func initDatabase(s settings, dbConfiguration dBConfiguration) (*dB, error) {
+func initDatabase(s settings, dbConfiguration dBConfiguration, locker sync.Locker) (*dB, error) {
 	fmt.Println("Initializing database...")
 	start := time.Now()
-	db, err := initDB(s, dbConfiguration)
+	db, err := initDB(s, dbConfiguration, locker)
 	fmt.Printf("%v elapsed.\n", time.Since(start))
 	return db, err
 }
@@ -154,9 +176,43 @@ func testBinary(s settings, dateString string, aesKeyFile string) {
 	if err != nil {
 		panic(err)
 	}
-	db, err := initDatabase(s, buildBinaryDbConfiguration(aesKeyFile))
+	db, err := initDatabase(s, buildBinaryDbConfiguration(aesKeyFile), nil)
 	if err != nil {
 		panic(err)
 	}
 	db.printChanges(date)
 }
+
+func printStats(s settings, aesKeyFile string) {
+	db, err := initDatabase(s, buildBinaryDbConfiguration(aesKeyFile), nil)
+	if err != nil {
+		panic(err)
+	}
+	db.printCacheStats()
+}
+
+func verify(s settings, aesKeyFile string) {
+	errs, err := verifyDB(s, buildBinaryDbConfiguration(aesKeyFile))
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range errs {
+		fmt.Println(e.Error())
+	}
+	if len(errs) > 0 {
+		fmt.Printf("%v bad files found\n", len(errs))
+		os.Exit(1)
+	}
+	fmt.Println("no bad files found")
+}
+
+func upgrade(s settings, aesKeyFile string) {
+	db, err := initDatabase(s, buildBinaryDbConfiguration(aesKeyFile), nil)
+	if err != nil {
+		panic(err)
+	}
+	err = db.upgrade()
+	if err != nil {
+		panic(err)
+	}
+}
@@ -1,6 +1,6 @@
 module SmartHome
 
-go 1.21
+go 1.23
 
 replace TimeSeriesData => ../TimeSeriesData
 
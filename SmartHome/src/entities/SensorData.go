@@ -2,68 +2,18 @@ package entities
 
 import (
 	"TimeSeriesData/core"
-	"encoding/binary"
 	"fmt"
 	"io"
+	"maps"
+	"slices"
 )
 
-type SensorDataItem struct {
-	EventTime int
-	Data      map[string]int
-}
+//go:generate go run TimeSeriesData/cmd/binarygen -type SensorDataItem
 
-func (i *SensorDataItem) save(writer io.Writer) error {
-	err := binary.Write(writer, binary.LittleEndian, uint32(i.EventTime))
-	if err != nil {
-		return err
-	}
-	err = binary.Write(writer, binary.LittleEndian, uint8(len(i.Data)))
-	if err != nil {
-		return err
-	}
-	for dataType, value := range i.Data {
-		err = core.WriteStringToBinary(writer, dataType)
-		if err != nil {
-			return err
-		}
-		err = binary.Write(writer, binary.LittleEndian, int32(value))
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func newSensorDataItemFromBinary(reader io.Reader) (SensorDataItem, error) {
-	i := SensorDataItem{
-		Data: make(map[string]int),
-	}
-	var eventTime uint32
-	err := binary.Read(reader, binary.LittleEndian, &eventTime)
-	if err != nil {
-		return i, err
-	}
-	i.EventTime = int(eventTime)
-	var l uint8
-	err = binary.Read(reader, binary.LittleEndian, &l)
-	if err != nil {
-		return i, err
-	}
-	for l > 0 {
-		var dataType string
-		dataType, err = core.ReadStringFromBinary(reader)
-		if err != nil {
-			return i, err
-		}
-		var value int32
-		err = binary.Read(reader, binary.LittleEndian, &value)
-		if err != nil {
-			return i, err
-		}
-		i.Data[dataType] = int(value)
-		l--
-	}
-	return i, nil
+// SensorDataItem value count is stored as uint8 in schema version 1
+type SensorDataItem struct {
+	EventTime int            `bin:"u32"`
+	Data      map[string]int `bin:"map[string]i32,len8"`
 }
 
 type SensorDataStats struct {
@@ -119,6 +69,27 @@ func (s *SensorData) PrintStats() {
 	}
 }
 
+// EstimateSize returns rough estimate of memory used by sensor data in bytes
+func (s *SensorData) EstimateSize() int {
+	size := 96
+	for _, list := range s.data {
+		size += 64
+		for _, item := range list {
+			size += 56
+			for dataType := range item.Data {
+				size += 32 + len(dataType)
+			}
+		}
+	}
+	for _, stats := range s.stats {
+		size += 64
+		for dataType := range stats {
+			size += 64 + len(dataType)
+		}
+	}
+	return size
+}
+
 func aggregate(data map[int][]SensorDataItem) map[int]map[string]SensorDataStats {
 	result := make(map[int]map[string]SensorDataStats)
 	for sensorId, list := range data {
@@ -131,22 +102,35 @@ func (s *SensorData) Aggregate() {
 	s.stats = aggregate(s.data)
 }
 
+// SensorDataFileKind is the kind of binary files with sensor data
+const SensorDataFileKind core.FileKind = 1
+
+func (s SensorData) FileKind() core.FileKind {
+	return SensorDataFileKind
+}
+
+// SchemaVersion 2 writes counts and sensor ids as varints
+func (s SensorData) SchemaVersion() int {
+	return 2
+}
+
 func (s SensorData) Save(writer io.Writer) error {
-	err := binary.Write(writer, binary.LittleEndian, uint16(len(s.data)))
+	err := core.WriteLength(writer, len(s.data))
 	if err != nil {
 		return err
 	}
-	for sensorId, list := range s.data {
-		err = binary.Write(writer, binary.LittleEndian, uint16(sensorId))
+	for _, sensorId := range slices.Sorted(maps.Keys(s.data)) {
+		err = core.WriteLength(writer, sensorId)
 		if err != nil {
 			return err
 		}
-		err = binary.Write(writer, binary.LittleEndian, uint16(len(list)))
+		list := s.data[sensorId]
+		err = core.WriteLength(writer, len(list))
 		if err != nil {
 			return err
 		}
 		for _, item := range list {
-			err = item.save(writer)
+			err = item.EncodeBinary(writer)
 			if err != nil {
 				return err
 			}
@@ -163,34 +147,33 @@ func NewSensorData(data map[int][]SensorDataItem) *SensorData {
 }
 
 func NewSensorDataFromBinary(reader io.Reader) (*SensorData, error) {
-	var length uint16
-	err := binary.Read(reader, binary.LittleEndian, &length)
+	length, err := core.ReadArrayLength(reader)
 	if err != nil {
 		return nil, err
 	}
 	data := make(map[int][]SensorDataItem)
 	for length > 0 {
-		var sensorId uint16
-		err = binary.Read(reader, binary.LittleEndian, &sensorId)
+		var sensorId int
+		sensorId, err = core.ReadLength(reader)
 		if err != nil {
 			return nil, err
 		}
-		var listLength uint16
-		err = binary.Read(reader, binary.LittleEndian, &listLength)
+		var listLength int
+		listLength, err = core.ReadArrayLength(reader)
 		if err != nil {
 			return nil, err
 		}
 		var list []SensorDataItem
 		for listLength > 0 {
 			var item SensorDataItem
-			item, err = newSensorDataItemFromBinary(reader)
+			err = item.DecodeBinary(reader)
 			if err != nil {
 				return nil, err
 			}
 			list = append(list, item)
 			listLength--
 		}
-		data[int(sensorId)] = list
+		data[sensorId] = list
 		length--
 	}
 	return NewSensorData(data), nil
@@ -0,0 +1,66 @@
+// Code generated by binarygen; DO NOT EDIT.
+
+package entities
+
+import (
+	"TimeSeriesData/core"
+	"io"
+	"maps"
+	"slices"
+)
+
+func (v SensorDataItem) EncodeBinary(writer io.Writer) error {
+	if err := core.WriteInteger(writer, core.BinaryUint32, v.EventTime); err != nil {
+		return err
+	}
+	if err := core.WriteLength8(writer, len(v.Data)); err != nil {
+		return err
+	}
+	for _, k := range slices.Sorted(maps.Keys(v.Data)) {
+		e := v.Data[k]
+		if err := core.WriteStringToBinary(writer, k); err != nil {
+			return err
+		}
+		if err := core.WriteInteger(writer, core.BinaryInt32, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *SensorDataItem) DecodeBinary(reader io.Reader) error {
+	{
+		x, err := core.ReadUint32(reader)
+		if err != nil {
+			return err
+		}
+		v.EventTime = int(x)
+	}
+	{
+		n, err := core.ReadLength8(reader)
+		if err != nil {
+			return err
+		}
+		v.Data = make(map[string]int)
+		for ; n > 0; n-- {
+			var k string
+			{
+				x, err := core.ReadStringFromBinary(reader)
+				if err != nil {
+					return err
+				}
+				k = x
+			}
+			var e int
+			{
+				x, err := core.ReadInt32(reader)
+				if err != nil {
+					return err
+				}
+				e = int(x)
+			}
+			v.Data[k] = e
+		}
+	}
+	return nil
+}
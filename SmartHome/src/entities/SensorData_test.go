@@ -1,8 +1,12 @@
 package entities
 
 import (
+	"TimeSeriesData/core"
 	"bytes"
+	"errors"
+	"math"
 	"reflect"
+	"strconv"
 	"testing"
 )
 
@@ -94,3 +98,107 @@ func TestNewSensorDataBinary(t *testing.T) {
 		t.Fatal("different data")
 	}
 }
+
+func TestSensorDataSaveIsDeterministic(t *testing.T) {
+	items := make(map[int][]SensorDataItem)
+	for sensorId := range 50 {
+		values := make(map[string]int)
+		for i := range 20 {
+			values["type"+strconv.Itoa(i)] = sensorId * i
+		}
+		items[sensorId] = []SensorDataItem{{EventTime: sensorId, Data: values}}
+	}
+	expected := new(bytes.Buffer)
+	err := NewSensorData(items).Save(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := NewSensorDataFromBinary(bytes.NewReader(expected.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 10 {
+		buffer := new(bytes.Buffer)
+		err = loaded.Save(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buffer.Bytes(), expected.Bytes()) {
+			t.Fatal("saved data should be byte-identical")
+		}
+	}
+}
+
+func TestSensorDataVarintFormat(t *testing.T) {
+	values := make(map[string]int)
+	for i := 0; i < math.MaxUint8+1; i++ {
+		values[strconv.Itoa(i)] = i
+	}
+	list := make([]SensorDataItem, math.MaxUint16+1)
+	for i := range list {
+		list[i] = SensorDataItem{EventTime: i, Data: map[string]int{"temp": i}}
+	}
+	list[0].Data = values
+	data := NewSensorData(map[int][]SensorDataItem{math.MaxUint16 + 1: list})
+	buffer := new(bytes.Buffer)
+	err := core.SaveBinaryStream(buffer, nil, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data2, err := core.LoadBinaryStreamP(buffer, nil, NewSensorDataFromBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, data2) {
+		t.Fatal("different data")
+	}
+	// schema version 1 format
+	err = data.Save(new(bytes.Buffer))
+	if err == nil {
+		t.Fatal("overflow should be detected")
+	}
+}
+
+func TestSensorDataItemBinaryLayout(t *testing.T) {
+	item := SensorDataItem{EventTime: 1, Data: map[string]int{"t": -2}}
+	buffer := new(bytes.Buffer)
+	err := item.EncodeBinary(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{1, 0, 0, 0, 1, 1, 0, 't', 0xFE, 0xFF, 0xFF, 0xFF}
+	if !bytes.Equal(buffer.Bytes(), expected) {
+		t.Fatalf("unexpected layout %v", buffer.Bytes())
+	}
+	var item2 SensorDataItem
+	err = item2.DecodeBinary(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(item, item2) {
+		t.Fatal("different items")
+	}
+}
+
+func FuzzSensorDataBinary(f *testing.F) {
+	data := buildTestData()
+	buffer := new(bytes.Buffer)
+	err := data.Save(buffer)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(buffer.Bytes())
+	buffer = new(bytes.Buffer)
+	err = core.SaveBinaryStream(buffer, nil, data)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(buffer.Bytes())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, err := core.LoadBinaryDataP(data, nil, NewSensorDataFromBinary)
+		var decodeErr *core.DecodeError
+		if err != nil && !errors.As(err, &decodeErr) {
+			t.Fatalf("DecodeError expected: %v", err)
+		}
+	})
+}
@@ -46,6 +46,18 @@ func (b *binaryDatedSource) Save(date int, data *entities.SensorData, dataFolder
 	return core.SaveBinary(b.getFileName(date, year, dataFolderPath), nil, data)
 }
 
+func (b *binaryDatedSource) Delete(date int, dataFolderPath string) error {
+	err := os.Remove(b.getFileName(date, strconv.Itoa(date/10000), dataFolderPath))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (b *binaryDatedSource) EstimateSize(data *entities.SensorData) int {
+	return data.EstimateSize()
+}
+
 type binaryDBConfiguration struct {
 }
 
@@ -11,7 +11,8 @@ type settings struct {
 	MinMonth                 int
 	TimeSeriesDataCapacity   int
 	MaxActiveTimeSeriesItems int
-	YearsToCreate            int
+	// limits estimated size of loaded days, zero disables the limit
+	MaxActiveTimeSeriesBytes int
 	DataFolderPath           string
 	ServerPort               int
 }
@@ -28,7 +29,7 @@ type dB struct {
 	configuration  dBConfiguration
 	sensors        core.DictionaryData[entities.Sensor]
 	locations      core.DictionaryData[entities.Location]
-	data           core.TimeSeriesData[entities.SensorData]
+	data           *core.TimeSeriesData[entities.SensorData]
 }
 
 func getSensorsFileName(dataFolderPath string) string {
@@ -65,18 +66,19 @@ func loadDB(s settings, configuration dBConfiguration) (*dB, error) {
 	if err != nil {
 		return nil, err
 	}
-	converter := newDateConverter(s.MinYear, s.MinMonth, s.YearsToCreate)
+	converter := newDateConverter(s.MinYear, s.MinMonth)
 	data, err := core.LoadTimeSeriesData[entities.SensorData](s.DataFolderPath+"/dates_new",
 		configuration.GetMainDataSource(), s.TimeSeriesDataCapacity, func(date int) int {
 			return converter.fromDate(date)
 		}, func(date int) int {
 			return date
-		}, s.MaxActiveTimeSeriesItems)
+		}, s.MaxActiveTimeSeriesItems, core.WithMaxActiveBytes[entities.SensorData](s.MaxActiveTimeSeriesBytes),
+		core.WithLoadProgress[entities.SensorData](core.PrintLoadProgress))
 	if err != nil {
 		return nil, err
 	}
-	return &dB{dataFolderPath: s.DataFolderPath, configuration: configuration, sensors: sensors, locations: locations,
-		data: data}, nil
+	return &dB{converter: converter, dataFolderPath: s.DataFolderPath, configuration: configuration, sensors: sensors,
+		locations: locations, data: data}, nil
 }
 
 func initDB(s settings, configuration dBConfiguration) (*dB, error) {
@@ -84,18 +86,50 @@ func initDB(s settings, configuration dBConfiguration) (*dB, error) {
 	if err != nil {
 		return nil, err
 	}
-	converter := newDateConverter(s.MinYear, s.MinMonth, s.YearsToCreate)
+	// removes dictionary files left by interrupted writes, data folders are cleaned by InitTimeSeriesData
+	err = core.RemoveFolderTempFiles(s.DataFolderPath)
+	if err != nil {
+		return nil, err
+	}
+	converter := newDateConverter(s.MinYear, s.MinMonth)
 	data, err := core.InitTimeSeriesData[entities.SensorData](getMainDataFolderPath(s.DataFolderPath),
 		configuration.GetMainDataSource(), s.TimeSeriesDataCapacity, func(date int) int {
 			return converter.fromDate(date)
 		}, func(date int) int {
 			return date
-		}, s.MaxActiveTimeSeriesItems)
+		}, s.MaxActiveTimeSeriesItems, core.WithMaxActiveBytes[entities.SensorData](s.MaxActiveTimeSeriesBytes))
 	if err != nil {
 		return nil, err
 	}
-	return &dB{dataFolderPath: s.DataFolderPath, configuration: configuration, sensors: sensors, locations: locations,
-		data: data}, nil
+	return &dB{converter: converter, dataFolderPath: s.DataFolderPath, configuration: configuration, sensors: sensors,
+		locations: locations, data: data}, nil
+}
+
+// verifyDB decodes all dictionary and data files and returns errors for all bad files
+func verifyDB(s settings, configuration dBConfiguration) ([]core.FileError, error) {
+	var result []core.FileError
+	path := getSensorsFileName(s.DataFolderPath)
+	_, err := configuration.GetSensors(path)
+	if err != nil {
+		result = append(result, core.FileError{FileName: path + ".json", Err: err})
+	}
+	path = getLocationsFileName(s.DataFolderPath)
+	_, err = configuration.GetLocations(path)
+	if err != nil {
+		result = append(result, core.FileError{FileName: path + ".json", Err: err})
+	}
+	converter := newDateConverter(s.MinYear, s.MinMonth)
+	data := core.NewTimeSeriesData[entities.SensorData](getMainDataFolderPath(s.DataFolderPath),
+		configuration.GetMainDataSource(), s.TimeSeriesDataCapacity, func(date int) int {
+			return converter.fromDate(date)
+		}, func(date int) int {
+			return date
+		}, 1)
+	errs, err := data.Verify()
+	if err != nil {
+		return nil, err
+	}
+	return append(result, errs...), nil
 }
 
 func (d *dB) printStats(date int) {
@@ -110,6 +144,32 @@ func (d *dB) printStats(date int) {
 	}
 }
 
+// printCacheStats reads all days and prints cache and I/O counters
+func (d *dB) printCacheStats() {
+	minIndex, maxIndex := d.data.Bounds()
+	if minIndex <= maxIndex {
+		i, err := d.data.Iterator(d.converter.toDate(minIndex), d.converter.toDate(maxIndex))
+		if err != nil {
+			panic(err)
+		}
+		for range i.All() {
+		}
+		if i.Err() != nil {
+			panic(i.Err())
+		}
+	}
+	fmt.Println(d.data.Stats())
+}
+
+// upgrade rewrites all database files in the latest schema versions
+func (d *dB) upgrade() error {
+	err := d.data.Rewrite()
+	if err != nil {
+		return err
+	}
+	return d.save()
+}
+
 func (d *dB) save() error {
 	return d.saveTo(d.dataFolderPath, d.configuration)
 }
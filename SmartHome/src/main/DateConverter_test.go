@@ -7,7 +7,7 @@ import (
 )
 
 func TestFrom(t *testing.T) {
-	converter := newDateConverter(1970, 1, 10)
+	converter := newDateConverter(1970, 1)
 	v := converter.fromDate(19710302)
 	shouldBe := 365 + 31 + 28 + 1
 	if v != shouldBe {
@@ -24,7 +24,7 @@ func TestFrom(t *testing.T) {
 		t.Fatal(fmt.Printf("fromDate 1970 1 19790101 error %v %v", v, shouldBe))
 	}
 
-	converter = newDateConverter(1970, 7, 2)
+	converter = newDateConverter(1970, 7)
 	v = converter.fromDate(19700715)
 	shouldBe = 14
 	if v != shouldBe {
@@ -46,7 +46,7 @@ func TestFrom(t *testing.T) {
 		t.Fatal(fmt.Printf("toDate 1970 7 19710302 error %v", d))
 	}
 
-	converter = newDateConverter(1972, 1, 10)
+	converter = newDateConverter(1972, 1)
 	v = converter.fromDate(19790101)
 	shouldBe = 2557
 	if v != shouldBe {
@@ -55,7 +55,7 @@ func TestFrom(t *testing.T) {
 }
 
 func TestFromTo(t *testing.T) {
-	converter := newDateConverter(2010, 1, 10)
+	converter := newDateConverter(2010, 1)
 	date := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
 	day := 0
 	for date.Year() < 2020 {
@@ -72,3 +72,25 @@ func TestFromTo(t *testing.T) {
 		day++
 	}
 }
+
+func TestOutOfRangeDates(t *testing.T) {
+	converter := newDateConverter(2010, 3)
+	for _, test := range []struct {
+		date  int
+		index int
+	}{
+		{20100228, -1},
+		{20100101, -59},
+		{19991231, -3713},
+		{21000301, 32872},
+	} {
+		idx := converter.fromDate(test.date)
+		if idx != test.index {
+			t.Fatalf("fromDate %v error %v %v", test.date, idx, test.index)
+		}
+		d := converter.toDate(idx)
+		if d != test.date {
+			t.Fatalf("toDate %v error %v", test.index, d)
+		}
+	}
+}
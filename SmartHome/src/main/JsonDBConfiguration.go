@@ -37,6 +37,14 @@ func (s jsonDatedSource) Save(date int, data *entities.SensorData, dataFolderPat
 	return errors.New("not implemented")
 }
 
+func (s jsonDatedSource) Delete(date int, dataFolderPath string) error {
+	return errors.New("not implemented")
+}
+
+func (s jsonDatedSource) EstimateSize(data *entities.SensorData) int {
+	return data.EstimateSize()
+}
+
 type jsonDBConfiguration struct{}
 
 func (c jsonDBConfiguration) GetSensors(fileName string) ([]entities.Sensor, error) {
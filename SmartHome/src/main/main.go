@@ -9,7 +9,7 @@ import (
 )
 
 func usage() {
-	fmt.Println("Usage: SmartHome config_file_name\n  test_json date\n  test date\n  migrate source_folder")
+	fmt.Println("Usage: SmartHome config_file_name\n  test_json date\n  test date\n  migrate source_folder\n  stats\n  verify\n  upgrade")
 }
 
 func main() {
@@ -41,6 +41,24 @@ func main() {
 		} else {
 			migrate(s, os.Args[3])
 		}
+	case "stats":
+		if l != 3 {
+			usage()
+		} else {
+			printStats(s)
+		}
+	case "verify":
+		if l != 3 {
+			usage()
+		} else {
+			verify(s)
+		}
+	case "upgrade":
+		if l != 3 {
+			usage()
+		} else {
+			upgrade(s)
+		}
 	default:
 		usage()
 	}
@@ -72,7 +90,7 @@ func migrate(s settings, sourceFolder string) {
 	destFolder := s.DataFolderPath
 	s.DataFolderPath = sourceFolder
 	db := buildDB(s, jsonDBConfiguration{})
-	err := db.saveTo(destFolder, binaryDBConfiguration{})
+	err := db.saveTo(destFolder, newBinaryDBConfiguration())
 	if err != nil {
 		panic(err)
 	}
@@ -92,6 +110,34 @@ func testBinary(s settings, dateString string) {
 	if err != nil {
 		panic(err)
 	}
-	db := initDatabase(s, binaryDBConfiguration{})
+	db := initDatabase(s, newBinaryDBConfiguration())
 	db.printStats(date)
 }
+
+func printStats(s settings) {
+	db := initDatabase(s, newBinaryDBConfiguration())
+	db.printCacheStats()
+}
+
+func verify(s settings) {
+	errs, err := verifyDB(s, newBinaryDBConfiguration())
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range errs {
+		fmt.Println(e.Error())
+	}
+	if len(errs) > 0 {
+		fmt.Printf("%v bad files found\n", len(errs))
+		os.Exit(1)
+	}
+	fmt.Println("no bad files found")
+}
+
+func upgrade(s settings) {
+	db := initDatabase(s, newBinaryDBConfiguration())
+	err := db.upgrade()
+	if err != nil {
+		panic(err)
+	}
+}
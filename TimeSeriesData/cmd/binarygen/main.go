@@ -0,0 +1,425 @@
+// binarygen generates EncodeBinary and DecodeBinary methods for structs with bin field tags
+// (see TimeSeriesData/core/Codec.go), so they are encoded without reflection.
+//
+// Usage:
+//
+//	//go:generate go run TimeSeriesData/cmd/binarygen -type Type1,Type2
+//
+// Types should be declared in the file containing the directive, the code is written to
+// the file with the Binary suffix added to its name.
+package main
+
+import (
+	"TimeSeriesData/core"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type generator struct {
+	buf      bytes.Buffer
+	usesMath bool
+	usesMaps bool
+}
+
+type generatorField struct {
+	name string
+	typ  ast.Expr
+	tag  core.BinaryTag
+}
+
+func main() {
+	typeNames := flag.String("type", "", "comma separated list of type names")
+	output := flag.String("output", "", "output file name")
+	flag.Parse()
+	fileName := os.Getenv("GOFILE")
+	if flag.NArg() > 0 {
+		fileName = flag.Arg(0)
+	}
+	if fileName == "" || *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(fileName, ".go") + "Binary.go"
+	}
+	code, err := generate(fileName, strings.Split(*typeNames, ","))
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = os.WriteFile(*output, code, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate returns formatted code of EncodeBinary and DecodeBinary methods for the types declared in the file
+func generate(fileName string, typeNames []string) ([]byte, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), fileName, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	var g generator
+	for _, typeName := range typeNames {
+		fields, err := findFields(file, typeName)
+		if err != nil {
+			return nil, err
+		}
+		err = g.generateEncoder(typeName, fields)
+		if err != nil {
+			return nil, err
+		}
+		err = g.generateDecoder(typeName, fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var header bytes.Buffer
+	header.WriteString("// Code generated by binarygen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&header, "package %v\n\nimport (\n\t\"TimeSeriesData/core\"\n\t\"io\"\n", file.Name.Name)
+	if g.usesMaps {
+		header.WriteString("\t\"maps\"\n")
+	}
+	if g.usesMath {
+		header.WriteString("\t\"math\"\n")
+	}
+	if g.usesMaps {
+		header.WriteString("\t\"slices\"\n")
+	}
+	header.WriteString(")\n")
+	header.Write(g.buf.Bytes())
+	return format.Source(header.Bytes())
+}
+
+// findFields returns fields with bin tags of the struct type
+func findFields(file *ast.File, typeName string) ([]generatorField, error) {
+	obj := file.Scope.Lookup(typeName)
+	if obj == nil || obj.Kind != ast.Typ {
+		return nil, fmt.Errorf("type %v is not found", typeName)
+	}
+	st, ok := obj.Decl.(*ast.TypeSpec).Type.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("type %v is not a struct", typeName)
+	}
+	var result []generatorField
+	for _, f := range st.Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		tagValue, err := strconv.Unquote(f.Tag.Value)
+		if err != nil {
+			return nil, err
+		}
+		binTag, ok := reflect.StructTag(tagValue).Lookup("bin")
+		if !ok || binTag == "-" {
+			continue
+		}
+		tag, err := core.ParseBinaryTag(binTag)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", typeName, err)
+		}
+		for _, name := range f.Names {
+			result = append(result, generatorField{name: name.Name, typ: f.Type, tag: tag})
+		}
+	}
+	return result, nil
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) generateEncoder(typeName string, fields []generatorField) error {
+	g.printf("\nfunc (v %v) EncodeBinary(writer io.Writer) error {\n", typeName)
+	for _, f := range fields {
+		target := "v." + f.name
+		if f.tag.NilMax || f.tag.NilEmpty {
+			star, ok := f.typ.(*ast.StarExpr)
+			if !ok {
+				return fmt.Errorf("%v.%v: nil option requires a pointer field", typeName, f.name)
+			}
+			g.printf("if %v != nil {\n", target)
+			err := g.encodeValue("*"+target, star.X, f.tag.BinaryType, false, 0)
+			if err != nil {
+				return fmt.Errorf("%v.%v: %v", typeName, f.name, err)
+			}
+			g.printf("} else {\n")
+			if f.tag.NilMax {
+				g.printf("if err := %v(writer, %v); err != nil {\nreturn err\n}\n", writeFunc(f.tag.Kind),
+					g.maxValue(f.tag.Kind))
+			} else {
+				g.printf("if err := core.WriteStringToBinary(writer, \"\"); err != nil {\nreturn err\n}\n")
+			}
+			g.printf("}\n")
+			continue
+		}
+		err := g.encodeValue(target, f.typ, f.tag.BinaryType, f.tag.Len8, 0)
+		if err != nil {
+			return fmt.Errorf("%v.%v: %v", typeName, f.name, err)
+		}
+	}
+	g.printf("return nil\n}\n")
+	return nil
+}
+
+func (g *generator) generateDecoder(typeName string, fields []generatorField) error {
+	g.printf("\nfunc (v *%v) DecodeBinary(reader io.Reader) error {\n", typeName)
+	for _, f := range fields {
+		target := "v." + f.name
+		if f.tag.NilMax || f.tag.NilEmpty {
+			star, ok := f.typ.(*ast.StarExpr)
+			if !ok {
+				return fmt.Errorf("%v.%v: nil option requires a pointer field", typeName, f.name)
+			}
+			g.printf("{\nx, err := %v(reader)\nif err != nil {\nreturn err\n}\n%v = nil\n", readFunc(f.tag.Kind), target)
+			if f.tag.NilMax {
+				g.printf("if x != %v {\n", g.maxValue(f.tag.Kind))
+			} else {
+				g.printf("if len(x) != 0 {\n")
+			}
+			g.printf("value := %v\n%v = &value\n}\n}\n", convert(star.X, goType(f.tag.Kind), "x"), target)
+			continue
+		}
+		err := g.decodeValue(target, f.typ, f.tag.BinaryType, f.tag.Len8, 0)
+		if err != nil {
+			return fmt.Errorf("%v.%v: %v", typeName, f.name, err)
+		}
+	}
+	g.printf("return nil\n}\n")
+	return nil
+}
+
+// variable returns the name of a local variable for the nesting level
+func variable(name string, level int) string {
+	if level == 0 {
+		return name
+	}
+	return name + strconv.Itoa(level)
+}
+
+func (g *generator) encodeValue(value string, typ ast.Expr, t core.BinaryType, len8 bool, level int) error {
+	switch t.Kind {
+	case core.BinaryStruct:
+		g.printf("if err := core.Encode(writer, %v); err != nil {\nreturn err\n}\n", value)
+	case core.BinarySlice:
+		at, ok := typ.(*ast.ArrayType)
+		if !ok || at.Len != nil {
+			return errors.New("slice type expected")
+		}
+		g.printf("if err := %v(writer, len(%v)); err != nil {\nreturn err\n}\n", countWriteFunc(len8), value)
+		e := variable("e", level)
+		g.printf("for _, %v := range %v {\n", e, value)
+		err := g.encodeValue(e, at.Elt, *t.Elem, false, level+1)
+		if err != nil {
+			return err
+		}
+		g.printf("}\n")
+	case core.BinaryMap:
+		mt, ok := typ.(*ast.MapType)
+		if !ok {
+			return errors.New("map type expected")
+		}
+		g.printf("if err := %v(writer, len(%v)); err != nil {\nreturn err\n}\n", countWriteFunc(len8), value)
+		k := variable("k", level)
+		e := variable("e", level)
+		// keys are sorted, so equal maps are encoded to equal bytes
+		g.printf("for _, %v := range slices.Sorted(maps.Keys(%v)) {\n%v := %v[%v]\n", k, value, e, value, k)
+		g.usesMaps = true
+		err := g.encodeValue(k, mt.Key, *t.Key, false, level+1)
+		if err != nil {
+			return err
+		}
+		err = g.encodeValue(e, mt.Value, *t.Elem, false, level+1)
+		if err != nil {
+			return err
+		}
+		g.printf("}\n")
+	default:
+		if t.Kind.IsInteger() && types.ExprString(typ) != goType(t.Kind) {
+			// values of other types are range checked
+			g.printf("if err := core.WriteInteger(writer, %v, %v); err != nil {\nreturn err\n}\n", kindConst(t.Kind),
+				value)
+			break
+		}
+		g.printf("if err := %v(writer, %v); err != nil {\nreturn err\n}\n", writeFunc(t.Kind),
+			convertTo(goType(t.Kind), typ, value))
+	}
+	return nil
+}
+
+func (g *generator) decodeValue(target string, typ ast.Expr, t core.BinaryType, len8 bool, level int) error {
+	switch t.Kind {
+	case core.BinaryStruct:
+		g.printf("if err := core.Decode(reader, &%v); err != nil {\nreturn err\n}\n", target)
+	case core.BinarySlice:
+		at, ok := typ.(*ast.ArrayType)
+		if !ok || at.Len != nil {
+			return errors.New("slice type expected")
+		}
+		n := variable("n", level)
+		e := variable("e", level)
+		g.printf("{\n%v, err := %v(reader)\nif err != nil {\nreturn err\n}\n%v = nil\n", n, countReadFunc(len8), target)
+		g.printf("for ; %v > 0; %v-- {\nvar %v %v\n", n, n, e, types.ExprString(at.Elt))
+		err := g.decodeValue(e, at.Elt, *t.Elem, false, level+1)
+		if err != nil {
+			return err
+		}
+		g.printf("%v = append(%v, %v)\n}\n}\n", target, target, e)
+	case core.BinaryMap:
+		mt, ok := typ.(*ast.MapType)
+		if !ok {
+			return errors.New("map type expected")
+		}
+		n := variable("n", level)
+		k := variable("k", level)
+		e := variable("e", level)
+		g.printf("{\n%v, err := %v(reader)\nif err != nil {\nreturn err\n}\n", n, countReadFunc(len8))
+		g.printf("%v = make(%v)\nfor ; %v > 0; %v-- {\nvar %v %v\n", target, types.ExprString(typ), n, n, k,
+			types.ExprString(mt.Key))
+		err := g.decodeValue(k, mt.Key, *t.Key, false, level+1)
+		if err != nil {
+			return err
+		}
+		g.printf("var %v %v\n", e, types.ExprString(mt.Value))
+		err = g.decodeValue(e, mt.Value, *t.Elem, false, level+1)
+		if err != nil {
+			return err
+		}
+		g.printf("%v[%v] = %v\n}\n}\n", target, k, e)
+	default:
+		g.printf("{\nx, err := %v(reader)\nif err != nil {\nreturn err\n}\n%v = %v\n}\n", readFunc(t.Kind), target,
+			convert(typ, goType(t.Kind), "x"))
+	}
+	return nil
+}
+
+// convert returns conversion of value of goType to typ
+func convert(typ ast.Expr, goType string, value string) string {
+	typeName := types.ExprString(typ)
+	if typeName == goType {
+		return value
+	}
+	return typeName + "(" + value + ")"
+}
+
+// convertTo returns conversion of value of typ to goType
+func convertTo(goType string, typ ast.Expr, value string) string {
+	if types.ExprString(typ) == goType {
+		return value
+	}
+	return goType + "(" + value + ")"
+}
+
+func goType(kind core.BinaryKind) string {
+	switch kind {
+	case core.BinaryUint8:
+		return "uint8"
+	case core.BinaryUint16:
+		return "uint16"
+	case core.BinaryUint32:
+		return "uint32"
+	case core.BinaryInt32:
+		return "int32"
+	case core.BinaryInt64:
+		return "int64"
+	case core.BinaryString:
+		return "string"
+	default:
+		return "int"
+	}
+}
+
+// kindConst returns the name of an integer kind constant
+func kindConst(kind core.BinaryKind) string {
+	switch kind {
+	case core.BinaryUint8:
+		return "core.BinaryUint8"
+	case core.BinaryUint16:
+		return "core.BinaryUint16"
+	case core.BinaryUint32:
+		return "core.BinaryUint32"
+	case core.BinaryInt32:
+		return "core.BinaryInt32"
+	default:
+		return "core.BinaryInt64"
+	}
+}
+
+func writeFunc(kind core.BinaryKind) string {
+	switch kind {
+	case core.BinaryUint8:
+		return "core.WriteUint8"
+	case core.BinaryUint16:
+		return "core.WriteUint16"
+	case core.BinaryUint32:
+		return "core.WriteUint32"
+	case core.BinaryInt32:
+		return "core.WriteInt32"
+	case core.BinaryInt64:
+		return "core.WriteInt64"
+	case core.BinaryString:
+		return "core.WriteStringToBinary"
+	default:
+		return "core.WriteLength"
+	}
+}
+
+func readFunc(kind core.BinaryKind) string {
+	switch kind {
+	case core.BinaryUint8:
+		return "core.ReadUint8"
+	case core.BinaryUint16:
+		return "core.ReadUint16"
+	case core.BinaryUint32:
+		return "core.ReadUint32"
+	case core.BinaryInt32:
+		return "core.ReadInt32"
+	case core.BinaryInt64:
+		return "core.ReadInt64"
+	case core.BinaryString:
+		return "core.ReadStringFromBinary"
+	default:
+		return "core.ReadLength"
+	}
+}
+
+func countWriteFunc(len8 bool) string {
+	if len8 {
+		return "core.WriteLength8"
+	}
+	return "core.WriteLength"
+}
+
+func countReadFunc(len8 bool) string {
+	if len8 {
+		return "core.ReadLength8"
+	}
+	return "core.ReadArrayLength"
+}
+
+func (g *generator) maxValue(kind core.BinaryKind) string {
+	g.usesMath = true
+	switch kind {
+	case core.BinaryUint8:
+		return "math.MaxUint8"
+	case core.BinaryUint16:
+		return "math.MaxUint16"
+	case core.BinaryUint32:
+		return "math.MaxUint32"
+	case core.BinaryInt32:
+		return "math.MaxInt32"
+	default:
+		return "math.MaxInt64"
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	code, err := generate(filepath.Join("testdata", "Sample.go"), []string{"Item", "Sample"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := os.ReadFile(filepath.Join("testdata", "SampleBinary.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(code, expected) {
+		t.Fatalf("unexpected generated code:\n%s", code)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "Types.go")
+	err := os.WriteFile(fileName, []byte("package types\n\ntype Code int\n\ntype Data struct {\n\tValue int `bin:\"i64,nil=max\"`\n}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, typeName := range []string{"Missing", "Code", "Data"} {
+		_, err = generate(fileName, []string{typeName})
+		if err == nil {
+			t.Fatalf("error expected for %v", typeName)
+		}
+	}
+}
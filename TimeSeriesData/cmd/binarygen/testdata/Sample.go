@@ -0,0 +1,21 @@
+package sample
+
+type Code uint16
+
+type Item struct {
+	Code  Code `bin:"u8"`
+	Value int  `bin:"i32"`
+}
+
+type Sample struct {
+	Id      int    `bin:"u32"`
+	Name    string `bin:"string"`
+	Skipped int
+	Amount  *int64         `bin:"i64,nil=max"`
+	Comment *string        `bin:"string,nil=empty"`
+	Parent  int            `bin:"len"`
+	Item    Item           `bin:"struct"`
+	Items   []Item         `bin:"[]struct"`
+	Codes   [][]Code       `bin:"[][]u16"`
+	Values  map[string]int `bin:"map[string]i32,len8"`
+}
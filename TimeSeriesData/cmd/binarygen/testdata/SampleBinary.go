@@ -0,0 +1,225 @@
+// Code generated by binarygen; DO NOT EDIT.
+
+package sample
+
+import (
+	"TimeSeriesData/core"
+	"io"
+	"maps"
+	"math"
+	"slices"
+)
+
+func (v Item) EncodeBinary(writer io.Writer) error {
+	if err := core.WriteInteger(writer, core.BinaryUint8, v.Code); err != nil {
+		return err
+	}
+	if err := core.WriteInteger(writer, core.BinaryInt32, v.Value); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *Item) DecodeBinary(reader io.Reader) error {
+	{
+		x, err := core.ReadUint8(reader)
+		if err != nil {
+			return err
+		}
+		v.Code = Code(x)
+	}
+	{
+		x, err := core.ReadInt32(reader)
+		if err != nil {
+			return err
+		}
+		v.Value = int(x)
+	}
+	return nil
+}
+
+func (v Sample) EncodeBinary(writer io.Writer) error {
+	if err := core.WriteInteger(writer, core.BinaryUint32, v.Id); err != nil {
+		return err
+	}
+	if err := core.WriteStringToBinary(writer, v.Name); err != nil {
+		return err
+	}
+	if v.Amount != nil {
+		if err := core.WriteInt64(writer, *v.Amount); err != nil {
+			return err
+		}
+	} else {
+		if err := core.WriteInt64(writer, math.MaxInt64); err != nil {
+			return err
+		}
+	}
+	if v.Comment != nil {
+		if err := core.WriteStringToBinary(writer, *v.Comment); err != nil {
+			return err
+		}
+	} else {
+		if err := core.WriteStringToBinary(writer, ""); err != nil {
+			return err
+		}
+	}
+	if err := core.WriteLength(writer, v.Parent); err != nil {
+		return err
+	}
+	if err := core.Encode(writer, v.Item); err != nil {
+		return err
+	}
+	if err := core.WriteLength(writer, len(v.Items)); err != nil {
+		return err
+	}
+	for _, e := range v.Items {
+		if err := core.Encode(writer, e); err != nil {
+			return err
+		}
+	}
+	if err := core.WriteLength(writer, len(v.Codes)); err != nil {
+		return err
+	}
+	for _, e := range v.Codes {
+		if err := core.WriteLength(writer, len(e)); err != nil {
+			return err
+		}
+		for _, e1 := range e {
+			if err := core.WriteInteger(writer, core.BinaryUint16, e1); err != nil {
+				return err
+			}
+		}
+	}
+	if err := core.WriteLength8(writer, len(v.Values)); err != nil {
+		return err
+	}
+	for _, k := range slices.Sorted(maps.Keys(v.Values)) {
+		e := v.Values[k]
+		if err := core.WriteStringToBinary(writer, k); err != nil {
+			return err
+		}
+		if err := core.WriteInteger(writer, core.BinaryInt32, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Sample) DecodeBinary(reader io.Reader) error {
+	{
+		x, err := core.ReadUint32(reader)
+		if err != nil {
+			return err
+		}
+		v.Id = int(x)
+	}
+	{
+		x, err := core.ReadStringFromBinary(reader)
+		if err != nil {
+			return err
+		}
+		v.Name = x
+	}
+	{
+		x, err := core.ReadInt64(reader)
+		if err != nil {
+			return err
+		}
+		v.Amount = nil
+		if x != math.MaxInt64 {
+			value := x
+			v.Amount = &value
+		}
+	}
+	{
+		x, err := core.ReadStringFromBinary(reader)
+		if err != nil {
+			return err
+		}
+		v.Comment = nil
+		if len(x) != 0 {
+			value := x
+			v.Comment = &value
+		}
+	}
+	{
+		x, err := core.ReadLength(reader)
+		if err != nil {
+			return err
+		}
+		v.Parent = x
+	}
+	if err := core.Decode(reader, &v.Item); err != nil {
+		return err
+	}
+	{
+		n, err := core.ReadArrayLength(reader)
+		if err != nil {
+			return err
+		}
+		v.Items = nil
+		for ; n > 0; n-- {
+			var e Item
+			if err := core.Decode(reader, &e); err != nil {
+				return err
+			}
+			v.Items = append(v.Items, e)
+		}
+	}
+	{
+		n, err := core.ReadArrayLength(reader)
+		if err != nil {
+			return err
+		}
+		v.Codes = nil
+		for ; n > 0; n-- {
+			var e []Code
+			{
+				n1, err := core.ReadArrayLength(reader)
+				if err != nil {
+					return err
+				}
+				e = nil
+				for ; n1 > 0; n1-- {
+					var e1 Code
+					{
+						x, err := core.ReadUint16(reader)
+						if err != nil {
+							return err
+						}
+						e1 = Code(x)
+					}
+					e = append(e, e1)
+				}
+			}
+			v.Codes = append(v.Codes, e)
+		}
+	}
+	{
+		n, err := core.ReadLength8(reader)
+		if err != nil {
+			return err
+		}
+		v.Values = make(map[string]int)
+		for ; n > 0; n-- {
+			var k string
+			{
+				x, err := core.ReadStringFromBinary(reader)
+				if err != nil {
+					return err
+				}
+				k = x
+			}
+			var e int
+			{
+				x, err := core.ReadInt32(reader)
+				if err != nil {
+					return err
+				}
+				e = int(x)
+			}
+			v.Values[k] = e
+		}
+	}
+	return nil
+}
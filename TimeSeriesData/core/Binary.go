@@ -1,10 +1,13 @@
 package core
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"os"
 	"reflect"
 )
@@ -14,48 +17,104 @@ type CryptoProcessor interface {
 	Decrypt(data []byte) ([]byte, error)
 }
 
+// StreamCryptoProcessor can be implemented by CryptoProcessor to encrypt files in authenticated segments,
+// so they can be decoded without reading the whole file into memory
+type StreamCryptoProcessor interface {
+	EncryptStream(writer io.Writer) (io.WriteCloser, error)
+	DecryptStream(reader io.Reader) (io.Reader, error)
+}
+
 type BinaryData interface {
 	Save(writer io.Writer) error
 }
 
+// VarintSchemaVersion is the first schema version in which lengths are written as varints
+const VarintSchemaVersion = 2
+
+type binarySaverItem struct {
+	data      any
+	saveIndex func(int, any, io.Writer) error
+}
+
+// BinarySaver encodes saved data when GetBytes or GetFileBytes is called, so it is written in the format
+// of the schema version these functions use
 type BinarySaver struct {
 	processor CryptoProcessor
-	data *bytes.Buffer
+	items     []binarySaverItem
+	schema    FileSchema
 }
 
 func NewBinarySaver(processor CryptoProcessor) *BinarySaver {
-	return &BinarySaver{processor: processor, data: new(bytes.Buffer)}
+	return &BinarySaver{processor: processor}
 }
 
 func (b *BinarySaver) Save(data any, saveIndex func(int, any, io.Writer) error) error {
-	bdata, ok := data.(BinaryData)
-	if ok {
-		return bdata.Save(b.data)
-	}
-	t := reflect.ValueOf(data)
-	if t.Kind() == reflect.Array || t.Kind() == reflect.Slice {
-		l := t.Len()
-		err := binary.Write(b.data, binary.LittleEndian, uint16(l))
+	if _, ok := data.(BinaryData); !ok {
+		t := reflect.ValueOf(data)
+		if t.Kind() != reflect.Array && t.Kind() != reflect.Slice {
+			return errors.New("unsupported data type")
+		}
+	}
+	b.schema = getFileSchema(data)
+	b.items = append(b.items, binarySaverItem{data: data, saveIndex: saveIndex})
+	return nil
+}
+
+func (b *BinarySaver) write(writer io.Writer) error {
+	for _, item := range b.items {
+		bdata, ok := item.data.(BinaryData)
+		if ok {
+			err := bdata.Save(writer)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		l := reflect.ValueOf(item.data).Len()
+		err := WriteLength(writer, l)
 		if err != nil {
 			return err
 		}
 		for i := 0; i < l; i++ {
-			err = saveIndex(i, data, b.data)
+			err = item.saveIndex(i, item.data, writer)
 			if err != nil {
 				return err
 			}
 		}
-		return nil
 	}
-	return errors.New("unsupported data type")
+	return nil
 }
 
-func (b *BinarySaver) GetBytes() []byte {
-	dataBytes := b.data.Bytes()
+// GetBytes returns saved data without the file header in schema version 1 format
+func (b *BinarySaver) GetBytes() ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	err := b.write(buffer)
+	if err != nil {
+		return nil, err
+	}
+	dataBytes := buffer.Bytes()
 	if b.processor != nil {
 		dataBytes = b.processor.Encrypt(dataBytes)
 	}
-	return dataBytes
+	return dataBytes, nil
+}
+
+// GetFileBytes returns saved data with the file header
+func (b *BinarySaver) GetFileBytes() ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	writer, err := EncodeFileStream(buffer, newFileHeader(b.schema, b.processor), b.processor)
+	if err != nil {
+		return nil, err
+	}
+	err = b.write(writer)
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
 }
 
 func (b *BinarySaver) GetFileExtension() string {
@@ -63,8 +122,7 @@ func (b *BinarySaver) GetFileExtension() string {
 }
 
 func LoadBinaryArray[T any](reader io.Reader, creator func(reader io.Reader) (T, error)) ([]T, error) {
-	var l uint16
-	err := binary.Read(reader, binary.LittleEndian, &l)
+	l, err := ReadArrayLength(reader)
 	if err != nil {
 		return nil, err
 	}
@@ -81,94 +139,115 @@ func LoadBinaryArray[T any](reader io.Reader, creator func(reader io.Reader) (T,
 }
 
 func LoadBinary[T any](fileName string, processor CryptoProcessor, creator func(reader io.Reader) (T, error)) (T, error) {
-	data, err := os.ReadFile(fileName)
+	f, err := os.Open(fileName)
 	if err != nil {
 		var object T
 		return object, err
 	}
-	return LoadBinaryData(data, processor, creator)
+	defer func() { _ = f.Close() }()
+	return LoadBinaryStream(f, processor, creator)
 }
 
 func LoadBinaryData[T any](data []byte, processor CryptoProcessor, creator func(reader io.Reader) (T, error)) (T, error) {
-	if processor != nil {
-		var err error
-		data, err = processor.Decrypt(data)
-		if err != nil {
-			var object T
-			return object, err
-		}
-	}
-	buffer := bytes.NewBuffer(data)
-	value, err := creator(buffer)
+	return LoadBinaryStream(bytes.NewReader(data), processor, creator)
+}
+
+// LoadBinaryStream decodes data from reader without reading it into memory, except for encrypted data that is
+// not segmented. Decoding errors are returned as DecodeError with the file name when reader is *os.File.
+func LoadBinaryStream[T any](reader io.Reader, processor CryptoProcessor, creator func(reader io.Reader) (T, error)) (T, error) {
+	return loadBinaryStream(reader, readerFileName(reader), processor, getTypeFileSchema[T](), getDecodeLimits[T](),
+		creator)
+}
+
+func loadBinaryStream[T any](reader io.Reader, fileName string, processor CryptoProcessor, schema FileSchema,
+	limits DecodeLimits, creator func(reader io.Reader) (T, error)) (T, error) {
+	r, err := decodeBinaryStream(reader, processor, schema, limits)
 	if err != nil {
-		return value, err
+		var object T
+		return object, NewDecodeError(nil, fileName, err)
 	}
-	if buffer.Len() != 0 {
-		return value, errors.New("non zero buffer length")
+	value, err := creator(r)
+	if err == nil {
+		err = r.checkEOF()
 	}
-	return value, err
+	return value, NewDecodeError(r, fileName, err)
 }
 
 func LoadBinaryP[T any](fileName string, processor CryptoProcessor, creator func(reader io.Reader) (*T, error)) (*T, error) {
-	data, err := os.ReadFile(fileName)
+	f, err := os.Open(fileName)
 	if err != nil {
 		return nil, err
 	}
-	return LoadBinaryDataP(data, processor, creator)
+	defer func() { _ = f.Close() }()
+	return LoadBinaryStreamP(f, processor, creator)
 }
 
 func LoadBinaryDataP[T any](data []byte, processor CryptoProcessor, creator func(reader io.Reader) (*T, error)) (*T, error) {
-	if processor != nil {
-		var err error
-		data, err = processor.Decrypt(data)
-		if err != nil {
-			return nil, err
-		}
-	}
-	buffer := bytes.NewBuffer(data)
-	value, err := creator(buffer)
+	return LoadBinaryStreamP(bytes.NewReader(data), processor, creator)
+}
+
+// LoadBinaryStreamP is LoadBinaryStream for creators that return pointers
+func LoadBinaryStreamP[T any](reader io.Reader, processor CryptoProcessor, creator func(reader io.Reader) (*T, error)) (*T, error) {
+	value, err := loadBinaryStream(reader, readerFileName(reader), processor, getTypeFileSchema[T](),
+		getDecodeLimits[T](), creator)
 	if err != nil {
 		return nil, err
 	}
-	if buffer.Len() != 0 {
-		return value, errors.New("non zero buffer length")
-	}
-	return value, err
+	return value, nil
 }
 
-func buildBytes(processor CryptoProcessor, buffer *bytes.Buffer) []byte {
-	data := buffer.Bytes()
-	if processor != nil {
-		return processor.Encrypt(data)
+func readerFileName(reader io.Reader) string {
+	if f, ok := reader.(*os.File); ok {
+		return f.Name()
 	}
-	return data
+	return ""
 }
 
-func buildBinaryDataBytes(processor CryptoProcessor, object BinaryData) ([]byte, error) {
+// buildBinaryFileBytes saves the object and adds the file header
+func buildBinaryFileBytes(processor CryptoProcessor, object BinaryData) ([]byte, error) {
 	buffer := new(bytes.Buffer)
-	err := object.Save(buffer)
+	err := SaveBinaryStream(buffer, processor, object)
 	if err != nil {
 		return nil, err
 	}
-	return buildBytes(processor, buffer), nil
+	return buffer.Bytes(), nil
 }
 
-func SaveBinary(fileName string, processor CryptoProcessor, object BinaryData) error {
-	data, err := buildBinaryDataBytes(processor, object)
+// SaveBinaryStream writes the file header and the object to writer
+func SaveBinaryStream(writer io.Writer, processor CryptoProcessor, object BinaryData) error {
+	w, err := EncodeFileStream(writer, newFileHeader(getFileSchema(object), processor), processor)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(fileName, data, 0644)
+	err = object.Save(w)
+	if err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func SaveBinary(fileName string, processor CryptoProcessor, object BinaryData) error {
+	return WriteFileAtomicFunc(fileName, 0644, func(writer io.Writer) error {
+		w := bufio.NewWriter(writer)
+		err := SaveBinaryStream(w, processor, object)
+		if err != nil {
+			return err
+		}
+		return w.Flush()
+	})
 }
 
 func ReadStringFromBinary(reader io.Reader) (string, error) {
-	var l uint16
-	err := binary.Read(reader, binary.LittleEndian, &l)
+	l, err := ReadLength(reader)
 	if err != nil || l == 0 {
 		return "", err
 	}
-	b := make([]byte, int(l))
-	err = binary.Read(reader, binary.LittleEndian, &b)
+	err = checkStringLength(reader, l)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, l)
+	_, err = io.ReadFull(reader, b)
 	if err != nil {
 		return "", err
 	}
@@ -176,13 +255,62 @@ func ReadStringFromBinary(reader io.Reader) (string, error) {
 }
 
 func WriteStringToBinary(writer io.Writer, value string) error {
-	var l uint16 = uint16(len(value))
-	err := binary.Write(writer, binary.LittleEndian, l)
+	err := WriteLength(writer, len(value))
 	if err != nil {
 		return err
 	}
-	if l > 0 {
-		return binary.Write(writer, binary.LittleEndian, []byte(value))
+	if len(value) > 0 {
+		_, err = io.WriteString(writer, value)
 	}
-	return nil
+	return err
+}
+
+type byteReader struct {
+	io.Reader
+}
+
+func (r byteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r.Reader, b[:])
+	return b[0], err
+}
+
+// ReadLength reads a length, a count or an id written by WriteLength
+func ReadLength(reader io.Reader) (int, error) {
+	if SchemaVersion(reader) < VarintSchemaVersion {
+		var l uint16
+		err := binary.Read(reader, binary.LittleEndian, &l)
+		return int(l), err
+	}
+	br, ok := reader.(io.ByteReader)
+	if !ok {
+		br = byteReader{reader}
+	}
+	l, err := binary.ReadUvarint(br)
+	if err != nil {
+		return 0, err
+	}
+	if l > math.MaxInt32 {
+		return 0, fmt.Errorf("length %v is out of range", l)
+	}
+	return int(l), nil
+}
+
+// WriteLength writes a length, a count or an id as a varint for VarintSchemaVersion and later versions,
+// as uint16 for older versions and data without the file header
+func WriteLength(writer io.Writer, value int) error {
+	if value < 0 {
+		return fmt.Errorf("negative length %v", value)
+	}
+	if WriterSchemaVersion(writer) < VarintSchemaVersion {
+		if value > math.MaxUint16 {
+			return fmt.Errorf("length %v does not fit in schema version 1 format", value)
+		}
+		return binary.Write(writer, binary.LittleEndian, uint16(value))
+	}
+	if value > math.MaxInt32 {
+		return fmt.Errorf("length %v is out of range", value)
+	}
+	_, err := writer.Write(binary.AppendUvarint(nil, uint64(value)))
+	return err
 }
@@ -2,10 +2,13 @@ package core
 
 import (
 	"TimeSeriesData/crypto"
+	"bytes"
 	"crypto/rand"
 	"encoding/binary"
 	"io"
+	"math"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -30,7 +33,11 @@ func TestBinarySaver(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	loaded, err := LoadBinaryData[testBinaryData](saver.GetBytes(), nil, newTestBinaryData)
+	data, err := saver.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBinaryData[testBinaryData](data, nil, newTestBinaryData)
 	if !reflect.DeepEqual(source, loaded) {
 		t.Fatal("different data")
 	}
@@ -49,7 +56,11 @@ func TestBinarySaver(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	loaded, err = LoadBinaryData[testBinaryData](saver.GetBytes(), processor, newTestBinaryData)
+	data, err = saver.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = LoadBinaryData[testBinaryData](data, processor, newTestBinaryData)
 	if !reflect.DeepEqual(source, loaded) {
 		t.Fatal("different data2")
 	}
@@ -67,7 +78,11 @@ func TestBinarySaverArray(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	loaded, err := LoadBinaryData[[]testBinaryData](saver.GetBytes(), nil, func(reader io.Reader) ([]testBinaryData, error) {
+	data, err := saver.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBinaryData[[]testBinaryData](data, nil, func(reader io.Reader) ([]testBinaryData, error) {
 		return LoadBinaryArray(reader, newTestBinaryData)
 	})
 	if len(loaded) != 3 || loaded[0].Id != 1 || loaded[1].Id != 2 || loaded[2].Id != 3 {
@@ -88,10 +103,113 @@ func TestBinarySaverArray(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	loaded, err = LoadBinaryData[[]testBinaryData](saver.GetBytes(), processor, func(reader io.Reader) ([]testBinaryData, error) {
+	data, err = saver.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = LoadBinaryData[[]testBinaryData](data, processor, func(reader io.Reader) ([]testBinaryData, error) {
 		return LoadBinaryArray(reader, newTestBinaryData)
 	})
 	if len(loaded) != 3 || loaded[0].Id != 1 || loaded[1].Id != 2 || loaded[2].Id != 3 {
 		t.Fatal("different data2")
 	}
 }
+
+type testLengths []int
+
+func (l testLengths) FileKind() FileKind {
+	return 1
+}
+
+func (l testLengths) SchemaVersion() int {
+	return VarintSchemaVersion
+}
+
+func (l testLengths) Save(writer io.Writer) error {
+	err := WriteLength(writer, len(l))
+	if err != nil {
+		return err
+	}
+	for _, v := range l {
+		err = WriteLength(writer, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTestLengths(reader io.Reader) (testLengths, error) {
+	return LoadBinaryArray(reader, ReadLength)
+}
+
+func TestLengths(t *testing.T) {
+	source := testLengths{0, 1, 127, 128, math.MaxUint16, math.MaxUint16 + 1, math.MaxInt32}
+	data, err := buildBinaryFileBytes(nil, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// varint sizes: count 1, values 1 + 1 + 1 + 2 + 3 + 3 + 5
+	if len(data) != fileHeaderSize+17 {
+		t.Fatalf("unexpected data length %v", len(data))
+	}
+	loaded, err := LoadBinaryData(data, nil, newTestLengths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(source, loaded) {
+		t.Fatalf("different data %v", loaded)
+	}
+	// schema version 1 format
+	buffer := new(bytes.Buffer)
+	err = source[:5].Save(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buffer.Len() != 12 {
+		t.Fatalf("unexpected data length %v", buffer.Len())
+	}
+	loaded, err = newTestLengths(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(source[:5], loaded) {
+		t.Fatalf("different data %v", loaded)
+	}
+	err = source.Save(new(bytes.Buffer))
+	if err == nil {
+		t.Fatal("uint16 overflow should be detected")
+	}
+	_, err = buildBinaryFileBytes(nil, testLengths{-1})
+	if err == nil {
+		t.Fatal("negative length should be rejected")
+	}
+	err = WriteStringToBinary(new(bytes.Buffer), strings.Repeat("a", math.MaxUint16+1))
+	if err == nil {
+		t.Fatal("string length overflow should be detected")
+	}
+}
+
+func TestBinarySaverFormats(t *testing.T) {
+	source := testLengths{math.MaxUint16 + 1}
+	saver := NewBinarySaver(nil)
+	err := saver.Save(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := saver.GetFileBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBinaryData(data, nil, newTestLengths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(source, loaded) {
+		t.Fatalf("different data %v", loaded)
+	}
+	_, err = saver.GetBytes()
+	if err == nil {
+		t.Fatal("uint16 overflow should be detected")
+	}
+}
@@ -0,0 +1,648 @@
+package core
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+/*
+
+Tag driven binary codec.
+
+Struct fields with the bin tag are encoded in declaration order, fields without the tag are skipped.
+Tag format: bin:"TYPE[,OPTION]..."
+
+TYPE:
+  u8, u16, u32, i32, i64 - little endian integer, the field can be of any integer type
+  len - integer written by WriteLength
+  string - string written by WriteStringToBinary
+  struct - nested struct encoded by Encode
+  []TYPE - slice, the element count is written by WriteLength
+  map[TYPE]TYPE - map, the element count is written by WriteLength
+
+OPTION:
+  nil=max - pointer field, nil is written as the maximum value of the integer type
+  nil=empty - pointer to string, nil is written as an empty string and an empty string is read as nil
+  len8 - slice or map element count is uint8 in schema version 1
+
+Types with generated code (see cmd/binarygen) implement BinaryEncoder and BinaryDecoder,
+Encode and Decode use these methods when they are available.
+
+*/
+
+// BinaryEncoder is implemented by types with generated encoding code
+type BinaryEncoder interface {
+	EncodeBinary(writer io.Writer) error
+}
+
+// BinaryDecoder is implemented by pointers to types with generated decoding code
+type BinaryDecoder interface {
+	DecodeBinary(reader io.Reader) error
+}
+
+type BinaryKind string
+
+const (
+	BinaryUint8  BinaryKind = "u8"
+	BinaryUint16 BinaryKind = "u16"
+	BinaryUint32 BinaryKind = "u32"
+	BinaryInt32  BinaryKind = "i32"
+	BinaryInt64  BinaryKind = "i64"
+	BinaryLength BinaryKind = "len"
+	BinaryString BinaryKind = "string"
+	BinaryStruct BinaryKind = "struct"
+	BinarySlice  BinaryKind = "slice"
+	BinaryMap    BinaryKind = "map"
+)
+
+// BinaryType is a parsed bin tag type
+type BinaryType struct {
+	Kind BinaryKind
+	// slice element or map value type
+	Elem *BinaryType
+	// map key type
+	Key *BinaryType
+}
+
+// BinaryTag is a parsed bin tag
+type BinaryTag struct {
+	BinaryType
+	NilMax   bool
+	NilEmpty bool
+	Len8     bool
+}
+
+// IsInteger returns true for fixed size integer kinds
+func (k BinaryKind) IsInteger() bool {
+	switch k {
+	case BinaryUint8, BinaryUint16, BinaryUint32, BinaryInt32, BinaryInt64:
+		return true
+	}
+	return false
+}
+
+// MaxValue returns the maximum value of an integer kind
+func (k BinaryKind) MaxValue() uint64 {
+	switch k {
+	case BinaryUint8:
+		return math.MaxUint8
+	case BinaryUint16:
+		return math.MaxUint16
+	case BinaryUint32:
+		return math.MaxUint32
+	case BinaryInt32:
+		return math.MaxInt32
+	default:
+		return math.MaxInt64
+	}
+}
+
+// MinValue returns the minimum value of an integer kind
+func (k BinaryKind) MinValue() int64 {
+	switch k {
+	case BinaryInt32:
+		return math.MinInt32
+	case BinaryInt64:
+		return math.MinInt64
+	default:
+		return 0
+	}
+}
+
+// Integer is satisfied by all integer types
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// CheckIntegerRange returns an error when the value does not fit in the integer kind
+func CheckIntegerRange[V Integer](kind BinaryKind, value V) error {
+	if value < 0 && int64(value) < kind.MinValue() || value >= 0 && uint64(value) > kind.MaxValue() {
+		return fmt.Errorf("value %v does not fit in %v", value, kind)
+	}
+	return nil
+}
+
+// WriteInteger writes the value as the integer kind, an error is returned when it is out of the kind range
+func WriteInteger[V Integer](writer io.Writer, kind BinaryKind, value V) error {
+	err := CheckIntegerRange(kind, value)
+	if err != nil {
+		return err
+	}
+	return writeInteger(writer, kind, uint64(value))
+}
+
+func parseBinaryType(s string) (BinaryType, error) {
+	if elem, ok := strings.CutPrefix(s, "[]"); ok {
+		t, err := parseBinaryType(elem)
+		if err != nil {
+			return BinaryType{}, err
+		}
+		return BinaryType{Kind: BinarySlice, Elem: &t}, nil
+	}
+	if rest, ok := strings.CutPrefix(s, "map["); ok {
+		key, value, ok := strings.Cut(rest, "]")
+		if !ok {
+			return BinaryType{}, fmt.Errorf("invalid map type %v", s)
+		}
+		k, err := parseBinaryType(key)
+		if err != nil {
+			return BinaryType{}, err
+		}
+		if k.Kind == BinarySlice || k.Kind == BinaryMap || k.Kind == BinaryStruct {
+			return BinaryType{}, fmt.Errorf("unsupported map key type %v", key)
+		}
+		v, err := parseBinaryType(value)
+		if err != nil {
+			return BinaryType{}, err
+		}
+		return BinaryType{Kind: BinaryMap, Key: &k, Elem: &v}, nil
+	}
+	kind := BinaryKind(s)
+	if kind.IsInteger() || kind == BinaryLength || kind == BinaryString || kind == BinaryStruct {
+		return BinaryType{Kind: kind}, nil
+	}
+	return BinaryType{}, fmt.Errorf("unknown type %v", s)
+}
+
+// ParseBinaryTag parses bin tag value
+func ParseBinaryTag(tag string) (BinaryTag, error) {
+	parts := strings.Split(tag, ",")
+	t, err := parseBinaryType(parts[0])
+	if err != nil {
+		return BinaryTag{}, err
+	}
+	result := BinaryTag{BinaryType: t}
+	for _, option := range parts[1:] {
+		switch option {
+		case "nil=max":
+			if !t.Kind.IsInteger() {
+				return BinaryTag{}, errors.New("nil=max requires an integer type")
+			}
+			result.NilMax = true
+		case "nil=empty":
+			if t.Kind != BinaryString {
+				return BinaryTag{}, errors.New("nil=empty requires string type")
+			}
+			result.NilEmpty = true
+		case "len8":
+			if t.Kind != BinarySlice && t.Kind != BinaryMap {
+				return BinaryTag{}, errors.New("len8 requires slice or map type")
+			}
+			result.Len8 = true
+		default:
+			return BinaryTag{}, fmt.Errorf("unknown option %v", option)
+		}
+	}
+	return result, nil
+}
+
+func WriteUint8(writer io.Writer, value uint8) error {
+	_, err := writer.Write([]byte{value})
+	return err
+}
+
+func ReadUint8(reader io.Reader) (uint8, error) {
+	var b [1]byte
+	_, err := io.ReadFull(reader, b[:])
+	return b[0], err
+}
+
+func WriteUint16(writer io.Writer, value uint16) error {
+	_, err := writer.Write(binary.LittleEndian.AppendUint16(nil, value))
+	return err
+}
+
+func ReadUint16(reader io.Reader) (uint16, error) {
+	var b [2]byte
+	_, err := io.ReadFull(reader, b[:])
+	return binary.LittleEndian.Uint16(b[:]), err
+}
+
+func WriteUint32(writer io.Writer, value uint32) error {
+	_, err := writer.Write(binary.LittleEndian.AppendUint32(nil, value))
+	return err
+}
+
+func ReadUint32(reader io.Reader) (uint32, error) {
+	var b [4]byte
+	_, err := io.ReadFull(reader, b[:])
+	return binary.LittleEndian.Uint32(b[:]), err
+}
+
+func WriteInt32(writer io.Writer, value int32) error {
+	return WriteUint32(writer, uint32(value))
+}
+
+func ReadInt32(reader io.Reader) (int32, error) {
+	v, err := ReadUint32(reader)
+	return int32(v), err
+}
+
+func WriteInt64(writer io.Writer, value int64) error {
+	_, err := writer.Write(binary.LittleEndian.AppendUint64(nil, uint64(value)))
+	return err
+}
+
+func ReadInt64(reader io.Reader) (int64, error) {
+	var b [8]byte
+	_, err := io.ReadFull(reader, b[:])
+	return int64(binary.LittleEndian.Uint64(b[:])), err
+}
+
+// WriteLength8 is WriteLength for lengths which are uint8 in schema version 1
+func WriteLength8(writer io.Writer, value int) error {
+	if WriterSchemaVersion(writer) < VarintSchemaVersion {
+		if value < 0 || value > math.MaxUint8 {
+			return fmt.Errorf("length %v does not fit in schema version 1 format", value)
+		}
+		return WriteUint8(writer, uint8(value))
+	}
+	return WriteLength(writer, value)
+}
+
+// ReadLength8 reads an array or a map length written by WriteLength8 and checks it with CheckArrayLength
+func ReadLength8(reader io.Reader) (int, error) {
+	if SchemaVersion(reader) < VarintSchemaVersion {
+		l, err := ReadUint8(reader)
+		if err == nil {
+			err = CheckArrayLength(reader, int(l))
+		}
+		if err != nil {
+			return 0, err
+		}
+		return int(l), nil
+	}
+	return ReadArrayLength(reader)
+}
+
+type codecField struct {
+	index int
+	tag   BinaryTag
+}
+
+var codecFields sync.Map
+
+// getCodecFields returns tagged fields of a struct type
+func getCodecFields(t reflect.Type) ([]codecField, error) {
+	if fields, ok := codecFields.Load(t); ok {
+		return fields.([]codecField), nil
+	}
+	var fields []codecField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tagValue, ok := f.Tag.Lookup("bin")
+		if !ok || tagValue == "-" {
+			continue
+		}
+		tag, err := ParseBinaryTag(tagValue)
+		if err != nil {
+			return nil, fmt.Errorf("%v.%v: %v", t.Name(), f.Name, err)
+		}
+		err = checkCodecType(f.Type, tag)
+		if err != nil {
+			return nil, fmt.Errorf("%v.%v: %v", t.Name(), f.Name, err)
+		}
+		fields = append(fields, codecField{index: i, tag: tag})
+	}
+	codecFields.Store(t, fields)
+	return fields, nil
+}
+
+func isIntegerType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// checkCodecType checks that the field type matches the tag
+func checkCodecType(t reflect.Type, tag BinaryTag) error {
+	if tag.NilMax || tag.NilEmpty {
+		if t.Kind() != reflect.Pointer {
+			return errors.New("nil option requires a pointer field")
+		}
+		t = t.Elem()
+	}
+	return checkBinaryType(t, tag.BinaryType)
+}
+
+func checkBinaryType(t reflect.Type, bt BinaryType) error {
+	ok := false
+	switch bt.Kind {
+	case BinaryString:
+		ok = t.Kind() == reflect.String
+	case BinaryStruct:
+		ok = t.Kind() == reflect.Struct
+	case BinarySlice:
+		if t.Kind() == reflect.Slice {
+			return checkBinaryType(t.Elem(), *bt.Elem)
+		}
+	case BinaryMap:
+		if t.Kind() == reflect.Map {
+			err := checkBinaryType(t.Key(), *bt.Key)
+			if err != nil {
+				return err
+			}
+			return checkBinaryType(t.Elem(), *bt.Elem)
+		}
+	default:
+		ok = isIntegerType(t)
+	}
+	if !ok {
+		return fmt.Errorf("type %v does not match %v", t, bt.Kind)
+	}
+	return nil
+}
+
+// Encode writes struct fields with bin tags
+func Encode(writer io.Writer, value any) error {
+	if encoder, ok := value.(BinaryEncoder); ok {
+		return encoder.EncodeBinary(writer)
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return errors.New("unsupported data type")
+	}
+	return encodeStruct(writer, v)
+}
+
+func encodeStruct(writer io.Writer, v reflect.Value) error {
+	fields, err := getCodecFields(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		fv := v.Field(f.index)
+		if f.tag.NilMax || f.tag.NilEmpty {
+			if fv.IsNil() {
+				if f.tag.NilMax {
+					err = writeInteger(writer, f.tag.Kind, f.tag.Kind.MaxValue())
+				} else {
+					err = WriteStringToBinary(writer, "")
+				}
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			fv = fv.Elem()
+		}
+		err = encodeValue(writer, fv, f.tag.BinaryType, f.tag.Len8)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedMapKeys returns map keys in ascending order, so equal maps are encoded to equal bytes
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	slices.SortFunc(keys, func(a, b reflect.Value) int {
+		switch {
+		case a.Kind() == reflect.String:
+			return cmp.Compare(a.String(), b.String())
+		case a.CanInt():
+			return cmp.Compare(a.Int(), b.Int())
+		default:
+			return cmp.Compare(a.Uint(), b.Uint())
+		}
+	})
+	return keys
+}
+
+func integerValue(v reflect.Value) uint64 {
+	if v.CanInt() {
+		return uint64(v.Int())
+	}
+	return v.Uint()
+}
+
+func writeInteger(writer io.Writer, kind BinaryKind, value uint64) error {
+	switch kind {
+	case BinaryUint8:
+		return WriteUint8(writer, uint8(value))
+	case BinaryUint16:
+		return WriteUint16(writer, uint16(value))
+	case BinaryUint32:
+		return WriteUint32(writer, uint32(value))
+	case BinaryInt32:
+		return WriteInt32(writer, int32(value))
+	default:
+		return WriteInt64(writer, int64(value))
+	}
+}
+
+func writeCount(writer io.Writer, count int, len8 bool) error {
+	if len8 {
+		return WriteLength8(writer, count)
+	}
+	return WriteLength(writer, count)
+}
+
+func encodeValue(writer io.Writer, v reflect.Value, t BinaryType, len8 bool) error {
+	switch t.Kind {
+	case BinaryLength:
+		return WriteLength(writer, int(integerValue(v)))
+	case BinaryString:
+		return WriteStringToBinary(writer, v.String())
+	case BinaryStruct:
+		return Encode(writer, v.Interface())
+	case BinarySlice:
+		err := writeCount(writer, v.Len(), len8)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			err = encodeValue(writer, v.Index(i), *t.Elem, false)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case BinaryMap:
+		err := writeCount(writer, v.Len(), len8)
+		if err != nil {
+			return err
+		}
+		for _, key := range sortedMapKeys(v) {
+			err = encodeValue(writer, key, *t.Key, false)
+			if err != nil {
+				return err
+			}
+			err = encodeValue(writer, v.MapIndex(key), *t.Elem, false)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if v.CanInt() {
+			return WriteInteger(writer, t.Kind, v.Int())
+		}
+		return WriteInteger(writer, t.Kind, v.Uint())
+	}
+}
+
+// Decode reads struct fields with bin tags, value should be a pointer to a struct
+func Decode(reader io.Reader, value any) error {
+	if decoder, ok := value.(BinaryDecoder); ok {
+		return decoder.DecodeBinary(reader)
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("unsupported data type")
+	}
+	return decodeStruct(reader, v.Elem())
+}
+
+func decodeStruct(reader io.Reader, v reflect.Value) error {
+	fields, err := getCodecFields(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		fv := v.Field(f.index)
+		if !f.tag.NilMax && !f.tag.NilEmpty {
+			err = decodeValue(reader, fv, f.tag.BinaryType, f.tag.Len8)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		value := reflect.New(fv.Type().Elem())
+		isNil := false
+		if f.tag.NilMax {
+			var i uint64
+			i, err = readInteger(reader, f.tag.Kind)
+			isNil = i == f.tag.Kind.MaxValue()
+			setInteger(value.Elem(), i)
+		} else {
+			var s string
+			s, err = ReadStringFromBinary(reader)
+			isNil = len(s) == 0
+			value.Elem().SetString(s)
+		}
+		if err != nil {
+			return err
+		}
+		if isNil {
+			fv.SetZero()
+		} else {
+			fv.Set(value)
+		}
+	}
+	return nil
+}
+
+func readInteger(reader io.Reader, kind BinaryKind) (uint64, error) {
+	switch kind {
+	case BinaryUint8:
+		v, err := ReadUint8(reader)
+		return uint64(v), err
+	case BinaryUint16:
+		v, err := ReadUint16(reader)
+		return uint64(v), err
+	case BinaryUint32:
+		v, err := ReadUint32(reader)
+		return uint64(v), err
+	case BinaryInt32:
+		v, err := ReadInt32(reader)
+		return uint64(int64(v)), err
+	default:
+		v, err := ReadInt64(reader)
+		return uint64(v), err
+	}
+}
+
+// setInteger sets an integer field, signed values are sign extended by readInteger
+func setInteger(v reflect.Value, value uint64) {
+	if v.CanInt() {
+		v.SetInt(int64(value))
+	} else {
+		v.SetUint(value)
+	}
+}
+
+func readCount(reader io.Reader, len8 bool) (int, error) {
+	if len8 {
+		return ReadLength8(reader)
+	}
+	return ReadArrayLength(reader)
+}
+
+func decodeValue(reader io.Reader, v reflect.Value, t BinaryType, len8 bool) error {
+	switch t.Kind {
+	case BinaryLength:
+		l, err := ReadLength(reader)
+		if err != nil {
+			return err
+		}
+		setInteger(v, uint64(l))
+		return nil
+	case BinaryString:
+		s, err := ReadStringFromBinary(reader)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+		return nil
+	case BinaryStruct:
+		return Decode(reader, v.Addr().Interface())
+	case BinarySlice:
+		l, err := readCount(reader, len8)
+		if err != nil {
+			return err
+		}
+		v.SetZero()
+		for ; l > 0; l-- {
+			e := reflect.New(v.Type().Elem()).Elem()
+			err = decodeValue(reader, e, *t.Elem, false)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, e))
+		}
+		return nil
+	case BinaryMap:
+		l, err := readCount(reader, len8)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.MakeMap(v.Type()))
+		for ; l > 0; l-- {
+			key := reflect.New(v.Type().Key()).Elem()
+			err = decodeValue(reader, key, *t.Key, false)
+			if err != nil {
+				return err
+			}
+			e := reflect.New(v.Type().Elem()).Elem()
+			err = decodeValue(reader, e, *t.Elem, false)
+			if err != nil {
+				return err
+			}
+			v.SetMapIndex(key, e)
+		}
+		return nil
+	default:
+		value, err := readInteger(reader, t.Kind)
+		if err != nil {
+			return err
+		}
+		setInteger(v, value)
+		return nil
+	}
+}
@@ -0,0 +1,205 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type testCodecCode uint16
+
+type testCodecItem struct {
+	Code  testCodecCode `bin:"u8"`
+	Value int           `bin:"i32"`
+}
+
+type testCodecData struct {
+	Id      int    `bin:"u32"`
+	Name    string `bin:"string"`
+	Skipped int
+	Amount  *int64          `bin:"i64,nil=max"`
+	Comment *string         `bin:"string,nil=empty"`
+	Parent  int             `bin:"len"`
+	Item    testCodecItem   `bin:"struct"`
+	Items   []testCodecItem `bin:"[]struct"`
+	Codes   []testCodecCode `bin:"[]u16"`
+	Values  map[string]int  `bin:"map[string]i32,len8"`
+}
+
+func TestCodec(t *testing.T) {
+	amount := int64(-5)
+	comment := "comment"
+	source := testCodecData{Id: 1, Name: "name", Skipped: 2, Amount: &amount, Comment: &comment, Parent: 3,
+		Item: testCodecItem{Code: 4, Value: -6}, Items: []testCodecItem{{1, 2}, {3, 4}}, Codes: []testCodecCode{7},
+		Values: map[string]int{"a": 8}}
+	buffer := new(bytes.Buffer)
+	err := Encode(buffer, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{1, 0, 0, 0, 4, 0, 'n', 'a', 'm', 'e', 0xFB, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		7, 0, 'c', 'o', 'm', 'm', 'e', 'n', 't', 3, 0, 4, 0xFA, 0xFF, 0xFF, 0xFF, 2, 0, 1, 2, 0, 0, 0, 3, 4, 0, 0, 0,
+		1, 0, 7, 0, 1, 1, 0, 'a', 8, 0, 0, 0}
+	if !bytes.Equal(buffer.Bytes(), expected) {
+		t.Fatalf("unexpected encoded data %v", buffer.Bytes())
+	}
+	var loaded testCodecData
+	err = Decode(buffer, &loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.Skipped = 0
+	if !reflect.DeepEqual(source, loaded) {
+		t.Fatalf("different data %v", loaded)
+	}
+	// nil values
+	source = testCodecData{Values: map[string]int{}}
+	buffer.Reset()
+	err = Encode(buffer, &source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buffer.Bytes()[6:14], []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F}) {
+		t.Fatalf("unexpected nil value %v", buffer.Bytes()[6:14])
+	}
+	err = Decode(buffer, &loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(source, loaded) {
+		t.Fatalf("different data %v", loaded)
+	}
+}
+
+func TestCodecIntegerRange(t *testing.T) {
+	for _, source := range []testCodecData{
+		{Id: math.MaxUint32 + 1},
+		{Id: -1},
+		{Item: testCodecItem{Code: math.MaxUint8 + 1}},
+		{Item: testCodecItem{Value: math.MinInt32 - 1}},
+		{Values: map[string]int{"a": math.MaxInt32 + 1}},
+	} {
+		err := Encode(new(bytes.Buffer), source)
+		if err == nil || !strings.Contains(err.Error(), "does not fit") {
+			t.Fatalf("overflow should be detected: %v", err)
+		}
+	}
+	for _, source := range []testCodecData{{Id: math.MaxUint32}, {Item: testCodecItem{Value: math.MinInt32}}} {
+		err := Encode(new(bytes.Buffer), source)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCodecVarintFormat(t *testing.T) {
+	values := make(map[string]int)
+	for _, key := range []string{"a", "b", "c"} {
+		values[key] = math.MaxInt32
+	}
+	source := testCodecData{Parent: math.MaxUint16 + 1, Items: make([]testCodecItem, math.MaxUint16+1),
+		Values: values}
+	buffer := new(bytes.Buffer)
+	err := Encode(buffer, source)
+	if err == nil {
+		t.Fatal("uint16 overflow should be detected")
+	}
+	buffer.Reset()
+	writer, err := EncodeFileStream(buffer, FileHeader{Version: VarintSchemaVersion}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Encode(writer, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBinaryData(buffer.Bytes(), nil, func(reader io.Reader) (testCodecData, error) {
+		var data testCodecData
+		err := Decode(reader, &data)
+		return data, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(source, loaded) {
+		t.Fatal("different data")
+	}
+}
+
+type testCodecCodes struct {
+	Codes map[testCodecCode]int `bin:"map[u16]i32"`
+}
+
+func TestCodecMapOrder(t *testing.T) {
+	values := make(map[string]int)
+	codes := make(map[testCodecCode]int)
+	for i := range 100 {
+		values[strconv.Itoa(i)] = i
+		codes[testCodecCode(100-i)] = i
+	}
+	for _, source := range []any{testCodecData{Values: values}, testCodecCodes{Codes: codes}} {
+		buffer := new(bytes.Buffer)
+		err := Encode(buffer, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := bytes.Clone(buffer.Bytes())
+		for range 10 {
+			buffer.Reset()
+			err = Encode(buffer, source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buffer.Bytes(), expected) {
+				t.Fatal("encoded data should be byte-identical")
+			}
+		}
+	}
+	buffer := new(bytes.Buffer)
+	err := Encode(buffer, testCodecCodes{Codes: map[testCodecCode]int{300: 1, 2: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buffer.Bytes(), []byte{2, 0, 2, 0, 3, 0, 0, 0, 0x2C, 1, 1, 0, 0, 0}) {
+		t.Fatalf("map keys should be sorted %v", buffer.Bytes())
+	}
+}
+
+type testBadCodecData struct {
+	Name int `bin:"string"`
+}
+
+func TestCodecTags(t *testing.T) {
+	for _, tag := range []string{"u64", "i64,nil=empty", "string,len8", "map[struct]u8", "map[u8", "u8,x"} {
+		_, err := ParseBinaryTag(tag)
+		if err == nil {
+			t.Fatalf("tag %v should be rejected", tag)
+		}
+	}
+	err := Encode(new(bytes.Buffer), testBadCodecData{})
+	if err == nil {
+		t.Fatal("field type should be checked")
+	}
+}
+
+func FuzzCodec(f *testing.F) {
+	amount := int64(-5)
+	buffer := new(bytes.Buffer)
+	err := Encode(buffer, testCodecData{Id: 1, Name: "name", Amount: &amount, Items: []testCodecItem{{1, 2}},
+		Codes: []testCodecCode{7}, Values: map[string]int{"a": 8}})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(buffer.Bytes())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = LoadBinaryData(data, nil, func(reader io.Reader) (testCodecData, error) {
+			var v testCodecData
+			err := Decode(reader, &v)
+			return v, err
+		})
+	})
+}
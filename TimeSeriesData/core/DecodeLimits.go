@@ -0,0 +1,155 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ErrDecodeLimit is wrapped by errors returned when decoded data exceeds DecodeLimits
+var ErrDecodeLimit = errors.New("decode limit exceeded")
+
+// DecodeLimits limits resources spent by decoders on corrupt or hostile data, zero fields mean no limit
+type DecodeLimits struct {
+	// maximal length of a string in bytes
+	MaxStringLength int
+	// maximal element count of an array or a map
+	MaxArrayLength int
+	// maximal size of decoded data (after decryption and decompression) in bytes
+	MaxTotalBytes int64
+}
+
+// DefaultDecodeLimits are used by LoadBinary* functions for types that do not implement DecodeLimiter
+// and by decoders reading from readers that were not created by this package
+var DefaultDecodeLimits = DecodeLimits{MaxStringLength: 1 << 20, MaxArrayLength: 1 << 24, MaxTotalBytes: 1 << 30}
+
+// DecodeLimiter can be implemented by loaded data or by the element type of loaded slices to replace
+// DefaultDecodeLimits
+type DecodeLimiter interface {
+	DecodeLimits() DecodeLimits
+}
+
+// DecodeError is returned by LoadBinary* functions and decoders of NewDecodeReader readers
+type DecodeError struct {
+	// empty when data was not read from a file
+	FileName string
+	// offset in decoded data (after decryption and decompression) at which the error was detected
+	Offset int64
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	if e.FileName == "" {
+		return fmt.Sprintf("offset %v: %v", e.Offset, e.Err)
+	}
+	return fmt.Sprintf("%v: offset %v: %v", e.FileName, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// NewDecodeReader returns a reader of schema version 1 data without the file header that counts read bytes
+// and applies limits to decoders reading from it
+func NewDecodeReader(reader io.Reader, limits DecodeLimits) io.Reader {
+	return &schemaReader{Reader: reader, source: reader, version: 1, limits: limits}
+}
+
+// NewDecodeError returns DecodeError with the offset of data read from reader, err is returned as is when
+// it is nil or already contains DecodeError
+func NewDecodeError(reader io.Reader, fileName string, err error) error {
+	var decodeErr *DecodeError
+	if err == nil || errors.As(err, &decodeErr) {
+		return err
+	}
+	var offset int64
+	if r, ok := reader.(*schemaReader); ok {
+		offset = r.offset
+	}
+	return &DecodeError{FileName: fileName, Offset: offset, Err: err}
+}
+
+// getDecodeLimits returns DecodeLimits of T, *T or of the element type of T slices, DefaultDecodeLimits when
+// DecodeLimiter is not implemented
+func getDecodeLimits[T any]() DecodeLimits {
+	var value T
+	limiter, ok := any(value).(DecodeLimiter)
+	if !ok {
+		limiter, ok = any(&value).(DecodeLimiter)
+	}
+	t := reflect.TypeOf(value)
+	if !ok && t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		limiter, ok = reflect.New(t.Elem()).Interface().(DecodeLimiter)
+	}
+	if ok {
+		return limiter.DecodeLimits()
+	}
+	return DefaultDecodeLimits
+}
+
+// readerDecodeLimits returns limits applied to decoders reading from reader
+func readerDecodeLimits(reader io.Reader) DecodeLimits {
+	if r, ok := reader.(*schemaReader); ok {
+		return r.limits
+	}
+	return DefaultDecodeLimits
+}
+
+// Read reads decoded data and checks MaxTotalBytes limit
+func (r *schemaReader) Read(p []byte) (int, error) {
+	if r.limits.MaxTotalBytes > 0 {
+		remaining := r.limits.MaxTotalBytes - r.offset
+		if remaining <= 0 {
+			return 0, fmt.Errorf("%w: data is longer than %v bytes", ErrDecodeLimit, r.limits.MaxTotalBytes)
+		}
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+	n, err := r.Reader.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+// checkRemaining returns an error when reader is an in-memory reader that has less than size bytes left
+func checkRemaining(reader io.Reader, size int) error {
+	if r, ok := reader.(*schemaReader); ok {
+		reader = r.Reader
+	}
+	if r, ok := reader.(interface{ Len() int }); ok && r.Len() < size {
+		return fmt.Errorf("length %v exceeds remaining data length %v", size, r.Len())
+	}
+	return nil
+}
+
+// checkStringLength checks that a string of the length can be read from reader
+func checkStringLength(reader io.Reader, length int) error {
+	limit := readerDecodeLimits(reader).MaxStringLength
+	if limit > 0 && length > limit {
+		return fmt.Errorf("%w: string length %v, maximal length is %v", ErrDecodeLimit, length, limit)
+	}
+	return checkRemaining(reader, length)
+}
+
+// CheckArrayLength checks that an array or a map of the length can be read from reader, it assumes that
+// every element takes at least one byte
+func CheckArrayLength(reader io.Reader, length int) error {
+	limit := readerDecodeLimits(reader).MaxArrayLength
+	if limit > 0 && length > limit {
+		return fmt.Errorf("%w: array length %v, maximal length is %v", ErrDecodeLimit, length, limit)
+	}
+	return checkRemaining(reader, length)
+}
+
+// ReadArrayLength reads an array or a map length written by WriteLength and checks it with CheckArrayLength
+func ReadArrayLength(reader io.Reader) (int, error) {
+	l, err := ReadLength(reader)
+	if err == nil {
+		err = CheckArrayLength(reader, l)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return l, nil
+}
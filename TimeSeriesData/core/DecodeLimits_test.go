@@ -0,0 +1,108 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isDecodeError checks that err is DecodeError with the message
+func isDecodeError(err error, message string) bool {
+	var decodeErr *DecodeError
+	return errors.As(err, &decodeErr) && decodeErr.Err.Error() == message
+}
+
+type testLimitedStrings []string
+
+func (s testLimitedStrings) DecodeLimits() DecodeLimits {
+	return DecodeLimits{MaxStringLength: 5, MaxArrayLength: 3, MaxTotalBytes: 100}
+}
+
+func (s testLimitedStrings) Save(writer io.Writer) error {
+	err := WriteLength(writer, len(s))
+	if err != nil {
+		return err
+	}
+	for _, v := range s {
+		err = WriteStringToBinary(writer, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTestLimitedStrings(reader io.Reader) (testLimitedStrings, error) {
+	return LoadBinaryArray(reader, ReadStringFromBinary)
+}
+
+func TestDecodeLimits(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.bin")
+	for _, test := range []struct {
+		data   testLimitedStrings
+		offset int64
+	}{
+		{testLimitedStrings{"a", "b", "c", "d"}, 2},
+		{testLimitedStrings{"a", "123456"}, 7},
+	} {
+		err := SaveBinary(fileName, nil, test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = LoadBinary(fileName, nil, newTestLimitedStrings)
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) || !errors.Is(err, ErrDecodeLimit) {
+			t.Fatalf("limit error expected: %v", err)
+		}
+		if decodeErr.FileName != fileName || decodeErr.Offset != test.offset {
+			t.Fatalf("unexpected error location: %v", err)
+		}
+	}
+	data := testLimitedStrings{"12345", "12345", "12345"}
+	_, err := LoadBinaryArray(bytes.NewReader(data.mustEncode(t)), ReadStringFromBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := NewDecodeReader(bytes.NewReader(data.mustEncode(t)), DecodeLimits{MaxTotalBytes: 10})
+	_, err = LoadBinaryArray(reader, ReadStringFromBinary)
+	if !errors.Is(err, ErrDecodeLimit) {
+		t.Fatalf("total bytes limit error expected: %v", err)
+	}
+	// lengths exceeding remaining data are rejected before allocation
+	for _, encoded := range [][]byte{{0xFF, 0xFF}, {1, 0, 0xFF, 0xFF}} {
+		_, err = LoadBinaryArray(bytes.NewReader(encoded), ReadStringFromBinary)
+		if err == nil || !strings.Contains(err.Error(), "exceeds remaining data length") {
+			t.Fatalf("length error expected: %v", err)
+		}
+	}
+}
+
+func (s testLimitedStrings) mustEncode(t testing.TB) []byte {
+	buffer := new(bytes.Buffer)
+	err := s.Save(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func FuzzLoadBinaryArray(f *testing.F) {
+	for _, data := range []testLimitedStrings{nil, {"a"}, {"12345", ""}} {
+		encoded, err := buildBinaryFileBytes(nil, data)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(encoded)
+		f.Add(data.mustEncode(f))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, err := LoadBinaryData(data, nil, newTestLimitedStrings)
+		var decodeErr *DecodeError
+		if err != nil && !errors.As(err, &decodeErr) {
+			t.Fatalf("DecodeError expected: %v", err)
+		}
+	})
+}
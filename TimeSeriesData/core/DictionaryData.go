@@ -3,7 +3,8 @@ package core
 import (
 	"errors"
 	"io"
-	"os"
+	"maps"
+	"slices"
 )
 
 type DataSource[T any] interface {
@@ -12,7 +13,10 @@ type DataSource[T any] interface {
 
 type DataSaver interface {
 	Save(data any, saveIndex func(int, any, io.Writer) error) error
-	GetBytes() []byte
+	// GetBytes returns saved data without the file header
+	GetBytes() ([]byte, error)
+	// GetFileBytes returns saved data in the file format
+	GetFileBytes() ([]byte, error)
 	GetFileExtension() string
 }
 
@@ -42,10 +46,56 @@ func (d *DictionaryData[T]) Get(idx int) (*T, error) {
 	return &v, nil
 }
 
+// NextId returns the id that will be assigned to the next added item
+func (d *DictionaryData[T]) NextId() int {
+	id := 0
+	for k := range d.data {
+		if k > id {
+			id = k
+		}
+	}
+	return id + 1
+}
+
+// Add allocates a new id and adds the item created by creator
+func (d *DictionaryData[T]) Add(creator func(id int) T) T {
+	v := creator(d.NextId())
+	d.data[v.GetId()] = v
+	return v
+}
+
+func (d *DictionaryData[T]) Update(v T) error {
+	_, ok := d.data[v.GetId()]
+	if !ok {
+		return errors.New("invalid " + d.name + " id")
+	}
+	d.data[v.GetId()] = v
+	return nil
+}
+
+func (d *DictionaryData[T]) Delete(idx int) error {
+	_, ok := d.data[idx]
+	if !ok {
+		return errors.New("invalid " + d.name + " id")
+	}
+	delete(d.data, idx)
+	return nil
+}
+
+// Find returns the item with the lowest id for which predicate returns true or nil if there is no such item
+func (d *DictionaryData[T]) Find(predicate func(T) bool) *T {
+	for _, k := range slices.Sorted(maps.Keys(d.data)) {
+		if v := d.data[k]; predicate(v) {
+			return &v
+		}
+	}
+	return nil
+}
+
 func (d *DictionaryData[T]) SaveTo(saver DataSaver, saveIndex func(int, any, io.Writer) error) error {
 	var list []T
-	for _, v := range d.data {
-		list = append(list, v)
+	for _, k := range slices.Sorted(maps.Keys(d.data)) {
+		list = append(list, d.data[k])
 	}
 	return saver.Save(list, saveIndex)
 }
@@ -55,7 +105,11 @@ func (d *DictionaryData[T]) SaveToFile(saver DataSaver, fileName string, saveInd
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(fileName+saver.GetFileExtension(), saver.GetBytes(), 0644)
+	data, err := saver.GetFileBytes()
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(fileName+saver.GetFileExtension(), data, 0644)
 }
 
 func (d *DictionaryData[T]) Save(saver DataSaver, fileName string, saveIndex func(int, any, io.Writer) error) error {
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+type testDictionaryItem struct {
+	id   int
+	name string
+}
+
+func (i testDictionaryItem) GetId() int {
+	return i.id
+}
+
+func TestDictionaryData(t *testing.T) {
+	d := NewDictionaryData[testDictionaryItem]("", "item", []testDictionaryItem{{1, "item1"}, {5, "item5"}})
+	item := d.Add(func(id int) testDictionaryItem { return testDictionaryItem{id, "item6"} })
+	if item.id != 6 {
+		t.Fatal("new item id should be 6")
+	}
+	err := d.Update(testDictionaryItem{1, "renamed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := d.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.name != "renamed" {
+		t.Fatal("item should be renamed")
+	}
+	if d.Update(testDictionaryItem{2, "item2"}) == nil {
+		t.Fatal("update of unknown item should fail")
+	}
+	err = d.Delete(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Delete(5) == nil {
+		t.Fatal("second delete should fail")
+	}
+	if d.Find(func(i testDictionaryItem) bool { return i.name == "item5" }) != nil {
+		t.Fatal("item5 should be deleted")
+	}
+	found := d.Find(func(i testDictionaryItem) bool { return i.name == "item6" })
+	if found == nil || found.id != 6 {
+		t.Fatal("item6 should be found")
+	}
+	for range 10 {
+		found = d.Find(func(i testDictionaryItem) bool { return i.id > 0 })
+		if found == nil || found.id != 1 {
+			t.Fatal("item with the lowest id should be found")
+		}
+	}
+	if d.NextId() != 7 {
+		t.Fatal("next id should be 7")
+	}
+}
+
+func saveTestDictionaryItemByIndex(index int, value any, writer io.Writer) error {
+	v := value.([]testDictionaryItem)
+	err := WriteLength(writer, v[index].id)
+	if err != nil {
+		return err
+	}
+	return WriteStringToBinary(writer, v[index].name)
+}
+
+func newTestDictionaryItem(reader io.Reader) (testDictionaryItem, error) {
+	id, err := ReadLength(reader)
+	if err != nil {
+		return testDictionaryItem{}, err
+	}
+	name, err := ReadStringFromBinary(reader)
+	return testDictionaryItem{id, name}, err
+}
+
+func saveTestDictionary(t *testing.T, d DictionaryData[testDictionaryItem]) []byte {
+	saver := NewBinarySaver(nil)
+	err := d.SaveTo(saver, saveTestDictionaryItemByIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := saver.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDictionaryDataSaveToOrder(t *testing.T) {
+	var items []testDictionaryItem
+	for i := 100; i > 0; i-- {
+		items = append(items, testDictionaryItem{i, "item" + strconv.Itoa(i)})
+	}
+	data := saveTestDictionary(t, NewDictionaryData[testDictionaryItem]("", "item", items))
+	loaded, err := LoadBinaryArray(bytes.NewReader(data), newTestDictionaryItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.IsSortedFunc(loaded, func(a, b testDictionaryItem) int { return a.id - b.id }) {
+		t.Fatal("items should be saved in id order")
+	}
+	for range 10 {
+		if !bytes.Equal(saveTestDictionary(t, NewDictionaryData[testDictionaryItem]("", "item", loaded)), data) {
+			t.Fatal("saved data should be byte-identical")
+		}
+	}
+}
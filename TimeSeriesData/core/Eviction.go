@@ -0,0 +1,192 @@
+package core
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// EvictionPolicy chooses loaded TimeSeriesData items to be removed from memory.
+// Policy instance keeps state of one TimeSeriesData, so it should not be shared.
+type EvictionPolicy[T any] interface {
+	// Attach is called when an item is loaded
+	Attach(item *LruItem[T])
+	// Touch is called when a loaded item is accessed
+	Touch(item *LruItem[T])
+	// Detach is called when an item is removed from memory
+	Detach(item *LruItem[T])
+	// Victim returns an item to be removed from memory
+	Victim() *LruItem[T]
+}
+
+type lfuEntry[T any] struct {
+	item  *LruItem[T]
+	count int
+	// last access time, used to choose from items with the same access count
+	tick  int
+	index int
+}
+
+type lfuHeap[T any] []*lfuEntry[T]
+
+func (h lfuHeap[T]) Len() int {
+	return len(h)
+}
+
+func (h lfuHeap[T]) Less(i, j int) bool {
+	if h[i].count == h[j].count {
+		return h[i].tick < h[j].tick
+	}
+	return h[i].count < h[j].count
+}
+
+func (h lfuHeap[T]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[T]) Push(x any) {
+	e := x.(*lfuEntry[T])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap[T]) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+// LfuPolicy is the least frequently used EvictionPolicy, least recently used item is chosen from items with the same
+// access count
+type LfuPolicy[T any] struct {
+	entries map[*LruItem[T]]*lfuEntry[T]
+	heap    lfuHeap[T]
+	tick    int
+}
+
+func NewLfuPolicy[T any]() *LfuPolicy[T] {
+	return &LfuPolicy[T]{entries: make(map[*LruItem[T]]*lfuEntry[T])}
+}
+
+func (p *LfuPolicy[T]) Attach(item *LruItem[T]) {
+	p.tick++
+	e := &lfuEntry[T]{item: item, count: 1, tick: p.tick}
+	p.entries[item] = e
+	heap.Push(&p.heap, e)
+}
+
+func (p *LfuPolicy[T]) Touch(item *LruItem[T]) {
+	e, ok := p.entries[item]
+	if !ok {
+		return
+	}
+	p.tick++
+	e.count++
+	e.tick = p.tick
+	heap.Fix(&p.heap, e.index)
+}
+
+func (p *LfuPolicy[T]) Detach(item *LruItem[T]) {
+	e, ok := p.entries[item]
+	if !ok {
+		return
+	}
+	heap.Remove(&p.heap, e.index)
+	delete(p.entries, item)
+}
+
+func (p *LfuPolicy[T]) Victim() *LruItem[T] {
+	if len(p.heap) == 0 {
+		return nil
+	}
+	return p.heap[0].item
+}
+
+// TwoQueuePolicy is the scan resistant 2Q EvictionPolicy.
+// Loaded items are added to the FIFO queue, items accessed again while they are in the FIFO queue or loaded again
+// shortly after removal are moved to the LRU queue. Items loaded once by a scan are removed from the FIFO queue,
+// so they do not push frequently used items out of memory.
+type TwoQueuePolicy[T any] struct {
+	maxInItems  int
+	maxOutItems int
+	in          *list.List
+	main        *list.List
+	elements    map[*LruItem[T]]*list.Element
+	inElements  map[*LruItem[T]]bool
+	// keys of items recently removed from the FIFO queue
+	out         *list.List
+	outElements map[int]*list.Element
+}
+
+// NewTwoQueuePolicy creates TwoQueuePolicy, maxInItems is the FIFO queue size, maxOutItems is the number of keys of
+// removed items to remember
+func NewTwoQueuePolicy[T any](maxInItems, maxOutItems int) *TwoQueuePolicy[T] {
+	return &TwoQueuePolicy[T]{
+		maxInItems:  maxInItems,
+		maxOutItems: maxOutItems,
+		in:          list.New(),
+		main:        list.New(),
+		elements:    make(map[*LruItem[T]]*list.Element),
+		inElements:  make(map[*LruItem[T]]bool),
+		out:         list.New(),
+		outElements: make(map[int]*list.Element),
+	}
+}
+
+func (p *TwoQueuePolicy[T]) Attach(item *LruItem[T]) {
+	if e, ok := p.outElements[item.Key]; ok {
+		p.out.Remove(e)
+		delete(p.outElements, item.Key)
+		p.elements[item] = p.main.PushFront(item)
+		return
+	}
+	p.elements[item] = p.in.PushFront(item)
+	p.inElements[item] = true
+}
+
+func (p *TwoQueuePolicy[T]) Touch(item *LruItem[T]) {
+	e, ok := p.elements[item]
+	if !ok {
+		return
+	}
+	if p.inElements[item] {
+		p.in.Remove(e)
+		delete(p.inElements, item)
+		p.elements[item] = p.main.PushFront(item)
+	} else {
+		p.main.MoveToFront(e)
+	}
+}
+
+func (p *TwoQueuePolicy[T]) Detach(item *LruItem[T]) {
+	e, ok := p.elements[item]
+	if !ok {
+		return
+	}
+	delete(p.elements, item)
+	if !p.inElements[item] {
+		p.main.Remove(e)
+		return
+	}
+	p.in.Remove(e)
+	delete(p.inElements, item)
+	p.outElements[item.Key] = p.out.PushFront(item.Key)
+	if p.out.Len() > p.maxOutItems {
+		last := p.out.Back()
+		p.out.Remove(last)
+		delete(p.outElements, last.Value.(int))
+	}
+}
+
+func (p *TwoQueuePolicy[T]) Victim() *LruItem[T] {
+	if p.in.Len() > 0 && (p.in.Len() >= p.maxInItems || p.main.Len() == 0) {
+		return p.in.Back().Value.(*LruItem[T])
+	}
+	if p.main.Len() > 0 {
+		return p.main.Back().Value.(*LruItem[T])
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package core
+
+import "testing"
+
+// scan loads recent months 90-99 twice, then iterates over the full history and returns months from 90-99 range
+// that were loaded again
+func scan(t *testing.T, policy EvictionPolicy[testData]) []int {
+	source := &countingDatedSource{loads: make(map[int]int)}
+	data := NewTimeSeriesData[testData]("", source, 100,
+		func(date int) int { return date }, func(date int) int { return date }, 10, WithEvictionPolicy(policy))
+	for i := 0; i < 100; i++ {
+		err := data.set(i, NewLruItem[testData](i, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for j := 0; j < 2; j++ {
+		for i := 90; i < 100; i++ {
+			_, err := data.GetExact(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	iter, err := data.Iterator(0, 89)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for iter.HasNext() {
+		_, _, err = iter.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 90; i < 100; i++ {
+		_, err = data.GetExact(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data.activeItems > 10 {
+		t.Fatalf("too many active items: %v", data.activeItems)
+	}
+	var reloaded []int
+	for i := 90; i < 100; i++ {
+		if source.loads[i] > 1 {
+			reloaded = append(reloaded, i)
+		}
+	}
+	return reloaded
+}
+
+func TestLruScan(t *testing.T) {
+	reloaded := scan(t, &LruManager[testData]{})
+	if len(reloaded) != 10 {
+		t.Fatalf("all recent months should be reloaded, reloaded %v", reloaded)
+	}
+}
+
+func TestLfuScan(t *testing.T) {
+	reloaded := scan(t, NewLfuPolicy[testData]())
+	if len(reloaded) > 1 {
+		t.Fatalf("recent months should survive the scan, reloaded %v", reloaded)
+	}
+}
+
+func TestTwoQueueScan(t *testing.T) {
+	reloaded := scan(t, NewTwoQueuePolicy[testData](3, 20))
+	if len(reloaded) > 3 {
+		t.Fatalf("recent months should survive the scan, reloaded %v", reloaded)
+	}
+}
+
+func TestTwoQueuePromotesReloadedItems(t *testing.T) {
+	p := NewTwoQueuePolicy[testData](2, 2)
+	items := []*LruItem[testData]{NewLruItem[testData](1, 1), NewLruItem[testData](2, 2),
+		NewLruItem[testData](3, 3)}
+	for _, item := range items {
+		p.Attach(item)
+	}
+	victim := p.Victim()
+	if victim != items[0] {
+		t.Fatal("first loaded item should be removed")
+	}
+	p.Detach(victim)
+	// item loaded again after removal goes to the main queue
+	p.Attach(victim)
+	if p.main.Len() != 1 || p.in.Len() != 2 {
+		t.Fatal("item should be moved to the main queue")
+	}
+	if p.Victim() != items[1] {
+		t.Fatal("FIFO queue item should be removed first")
+	}
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const tempFileSuffix = ".tmp"
+
+func getTempFileName(fileName string) string {
+	return filepath.Join(filepath.Dir(fileName), "."+filepath.Base(fileName)+tempFileSuffix)
+}
+
+func isTempFileName(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, tempFileSuffix)
+}
+
+// WriteFileAtomic writes data to a temporary file, flushes it to the disk and renames it to fileName,
+// so after a crash fileName contains either old or new data
+func WriteFileAtomic(fileName string, data []byte, perm os.FileMode) error {
+	return WriteFileAtomicFunc(fileName, perm, func(writer io.Writer) error {
+		_, err := writer.Write(data)
+		return err
+	})
+}
+
+// WriteFileAtomicFunc is WriteFileAtomic for data written by write function
+func WriteFileAtomicFunc(fileName string, perm os.FileMode, write func(writer io.Writer) error) error {
+	tempFileName := getTempFileName(fileName)
+	f, err := os.OpenFile(tempFileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFileName, fileName)
+	}
+	if err != nil {
+		_ = os.Remove(tempFileName)
+		return err
+	}
+	return syncDir(filepath.Dir(fileName))
+}
+
+// RemoveTempFiles removes temporary files left in the folder and its subfolders by interrupted writes
+func RemoveTempFiles(folder string) error {
+	return removeTempFiles(folder, true)
+}
+
+// RemoveFolderTempFiles removes temporary files left in the folder by interrupted writes, subfolders are skipped
+func RemoveFolderTempFiles(folder string) error {
+	return removeTempFiles(folder, false)
+}
+
+func removeTempFiles(folder string, recursive bool) error {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := filepath.Join(folder, entry.Name())
+		if entry.IsDir() {
+			if recursive {
+				err = removeTempFiles(name, true)
+			}
+		} else if isTempFileName(entry.Name()) {
+			err = os.Remove(name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+/*
+
+Binary file structure:
+|magic - 4 bytes|file kind - 1 byte|schema version - 2 bytes|flags - 1 byte|data|
+
+Data is compressed with DEFLATE when FileCompressed flag is set and then encrypted when FileEncrypted flag is set.
+Encrypted data is a single CryptoProcessor.Encrypt result or a stream of StreamCryptoProcessor segments when
+FileSegmented flag is set.
+Files without the header (written before it was introduced) are read as schema version 1 files of unknown kind,
+IsHeaderlessFile reports them to creators.
+
+*/
+
+var fileMagic = []byte{0x89, 'T', 'S', 'D'}
+
+const fileHeaderSize = 8
+
+// FileKind identifies contents of a binary file, values are defined by applications
+type FileKind uint8
+
+const UnknownFileKind FileKind = 0
+
+// file flags
+const (
+	FileEncrypted  uint8 = 1
+	FileCompressed uint8 = 2
+	// data is encrypted in authenticated segments by StreamCryptoProcessor, used with FileEncrypted
+	FileSegmented uint8 = 4
+)
+
+type FileHeader struct {
+	Kind    FileKind
+	Version int
+	Flags   uint8
+}
+
+// FileSchema can be implemented by saved data or by the element type of saved slices to set the file kind and
+// the schema version written by Save. Loaders reject files of other kinds and files of newer schema versions.
+type FileSchema interface {
+	FileKind() FileKind
+	SchemaVersion() int
+}
+
+type schemaReader struct {
+	io.Reader
+	// decrypted data before decompression
+	source  io.Reader
+	version int
+	limits  DecodeLimits
+	// count of bytes read by Read
+	offset int64
+	// data was read from a file without the header
+	headerless bool
+}
+
+type schemaWriter struct {
+	io.WriteCloser
+	version int
+}
+
+// SchemaVersion returns schema version of data decoded by the reader, so creators passed to LoadBinary* functions
+// can read older layouts. It returns 1 for readers that were not created by LoadBinary* functions.
+func SchemaVersion(reader io.Reader) int {
+	if r, ok := reader.(*schemaReader); ok {
+		return r.version
+	}
+	return 1
+}
+
+// IsHeaderlessFile returns true when data decoded by the reader was read by LoadBinary* functions from a file
+// without the header, so creators can detect layouts that were changed before the header was introduced
+func IsHeaderlessFile(reader io.Reader) bool {
+	r, ok := reader.(*schemaReader)
+	return ok && r.headerless
+}
+
+// WriterSchemaVersion returns schema version of data encoded by the writer. It returns 1 for writers that were not
+// created by EncodeFileStream.
+func WriterSchemaVersion(writer io.Writer) int {
+	if w, ok := writer.(*schemaWriter); ok {
+		return w.version
+	}
+	return 1
+}
+
+// getFileSchema returns FileSchema of the value or of the element type of a slice, nil when it is not implemented
+func getFileSchema(value any) FileSchema {
+	if schema, ok := value.(FileSchema); ok {
+		return schema
+	}
+	t := reflect.TypeOf(value)
+	if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		if schema, ok := reflect.New(t.Elem()).Interface().(FileSchema); ok {
+			return schema
+		}
+	}
+	return nil
+}
+
+// getTypeFileSchema returns FileSchema of T, *T or of the element type of T slices, nil when it is not implemented
+func getTypeFileSchema[T any]() FileSchema {
+	var value T
+	schema := getFileSchema(value)
+	if schema == nil {
+		schema, _ = any(&value).(FileSchema)
+	}
+	return schema
+}
+
+func newFileHeader(schema FileSchema, processor CryptoProcessor) FileHeader {
+	header := FileHeader{Kind: UnknownFileKind, Version: 1}
+	if schema != nil {
+		header.Kind = schema.FileKind()
+		header.Version = schema.SchemaVersion()
+	}
+	if processor != nil {
+		header.Flags = FileEncrypted
+		if _, ok := processor.(StreamCryptoProcessor); ok {
+			header.Flags |= FileSegmented
+		}
+	}
+	return header
+}
+
+type blobEncryptWriter struct {
+	bytes.Buffer
+	writer    io.Writer
+	processor CryptoProcessor
+}
+
+// Close encrypts buffered data and writes it to the underlying writer
+func (w *blobEncryptWriter) Close() error {
+	_, err := w.writer.Write(w.processor.Encrypt(w.Bytes()))
+	return err
+}
+
+type multiCloser struct {
+	io.Writer
+	closers []io.Closer
+}
+
+// Close closes writers in order from the outermost one
+func (w multiCloser) Close() error {
+	for _, c := range w.closers {
+		err := c.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeFileStream writes the file header to writer and returns a writer that compresses and encrypts data
+// according to header flags and passes schema version to WriteLength. Close should be called after writing
+// all data, it does not close writer.
+func EncodeFileStream(writer io.Writer, header FileHeader, processor CryptoProcessor) (io.WriteCloser, error) {
+	if (header.Flags&FileEncrypted != 0) != (processor != nil) {
+		return nil, errors.New("file encrypted flag does not match crypto processor")
+	}
+	if header.Flags&^(FileEncrypted|FileCompressed|FileSegmented) != 0 {
+		return nil, fmt.Errorf("unsupported file flags %v", header.Flags)
+	}
+	headerBytes := make([]byte, 0, fileHeaderSize)
+	headerBytes = append(headerBytes, fileMagic...)
+	headerBytes = append(headerBytes, uint8(header.Kind))
+	headerBytes = binary.LittleEndian.AppendUint16(headerBytes, uint16(header.Version))
+	headerBytes = append(headerBytes, header.Flags)
+	_, err := writer.Write(headerBytes)
+	if err != nil {
+		return nil, err
+	}
+	result := multiCloser{Writer: writer}
+	if processor != nil {
+		var encryptor io.WriteCloser
+		if header.Flags&FileSegmented != 0 {
+			streamProcessor, ok := processor.(StreamCryptoProcessor)
+			if !ok {
+				return nil, errors.New("crypto processor does not support segmented encryption")
+			}
+			encryptor, err = streamProcessor.EncryptStream(writer)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			encryptor = &blobEncryptWriter{writer: writer, processor: processor}
+		}
+		result.Writer = encryptor
+		result.closers = []io.Closer{encryptor}
+	}
+	if header.Flags&FileCompressed != 0 {
+		compressor, err := flate.NewWriter(result.Writer, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		result.Writer = compressor
+		result.closers = append([]io.Closer{compressor}, result.closers...)
+	}
+	return &schemaWriter{WriteCloser: result, version: header.Version}, nil
+}
+
+// EncodeFile adds the file header to data and compresses and encrypts it according to header flags
+func EncodeFile(header FileHeader, processor CryptoProcessor, data []byte) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	writer, err := EncodeFileStream(buffer, header, processor)
+	if err != nil {
+		return nil, err
+	}
+	_, err = writer.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// decryptBlob reads the rest of data and decrypts it as a whole
+func decryptBlob(reader io.Reader, processor CryptoProcessor) (io.Reader, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	data, err = processor.Decrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// decodedFile contains readers of decoded file data
+type decodedFile struct {
+	header FileHeader
+	// decrypted data
+	source io.Reader
+	// decrypted and decompressed data
+	data io.Reader
+	// the file was written before the header was introduced
+	headerless bool
+}
+
+// DecodeFileStream parses the file header and returns a reader of decrypted and decompressed data.
+// Data without the header is decrypted as a whole when processor is not nil.
+func DecodeFileStream(reader io.Reader, processor CryptoProcessor) (FileHeader, io.Reader, error) {
+	f, err := decodeFileStream(reader, processor)
+	return f.header, f.data, err
+}
+
+func decodeFileStream(reader io.Reader, processor CryptoProcessor) (decodedFile, error) {
+	r, ok := reader.(*bufio.Reader)
+	if !ok {
+		r = bufio.NewReader(reader)
+	}
+	header := FileHeader{Kind: UnknownFileKind, Version: 1}
+	headerBytes, err := r.Peek(fileHeaderSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return decodedFile{header: header}, err
+	}
+	if len(headerBytes) < fileHeaderSize || !bytes.Equal(headerBytes[:len(fileMagic)], fileMagic) {
+		// file without the header
+		if processor == nil {
+			return decodedFile{header: header, source: r, data: r, headerless: true}, nil
+		}
+		header.Flags = FileEncrypted
+		var data io.Reader
+		data, err = decryptBlob(r, processor)
+		return decodedFile{header: header, source: data, data: data, headerless: true}, err
+	}
+	header = FileHeader{Kind: FileKind(headerBytes[4]), Version: int(binary.LittleEndian.Uint16(headerBytes[5:])),
+		Flags: headerBytes[7]}
+	_, _ = r.Discard(fileHeaderSize)
+	if header.Flags&^(FileEncrypted|FileCompressed|FileSegmented) != 0 {
+		return decodedFile{header: header}, fmt.Errorf("unsupported file flags %v", header.Flags)
+	}
+	result := decodedFile{header: header, source: r}
+	if header.Flags&FileEncrypted != 0 {
+		if processor == nil {
+			return result, errors.New("file is encrypted")
+		}
+		if header.Flags&FileSegmented != 0 {
+			streamProcessor, ok := processor.(StreamCryptoProcessor)
+			if !ok {
+				return result, errors.New("crypto processor does not support segmented encryption")
+			}
+			result.source, err = streamProcessor.DecryptStream(r)
+		} else {
+			result.source, err = decryptBlob(r, processor)
+		}
+		if err != nil {
+			return result, err
+		}
+	} else if processor != nil {
+		return result, errors.New("file is not encrypted")
+	}
+	result.data = result.source
+	if header.Flags&FileCompressed != 0 {
+		result.data = flate.NewReader(result.source)
+	}
+	return result, nil
+}
+
+// DecodeFile parses the file header, decrypts and decompresses data. Data without the header is decrypted
+// when processor is not nil. Decompressed data is limited by DefaultDecodeLimits.MaxTotalBytes.
+func DecodeFile(data []byte, processor CryptoProcessor) (FileHeader, []byte, error) {
+	f, err := decodeFileStream(bytes.NewReader(data), processor)
+	if err != nil {
+		return f.header, nil, err
+	}
+	reader := &schemaReader{Reader: f.data, source: f.source, version: f.header.Version, limits: DefaultDecodeLimits,
+		headerless: f.headerless}
+	data, err = io.ReadAll(reader)
+	return f.header, data, err
+}
+
+// checkFileSchema checks that the file can be decoded by the current version of T creator
+func checkFileSchema(header FileHeader, schema FileSchema) error {
+	if schema == nil || header.Kind == UnknownFileKind {
+		return nil
+	}
+	if header.Kind != schema.FileKind() {
+		return fmt.Errorf("unexpected file kind %v, expected %v", header.Kind, schema.FileKind())
+	}
+	if header.Version > schema.SchemaVersion() {
+		return fmt.Errorf("unsupported schema version %v, latest supported version is %v", header.Version,
+			schema.SchemaVersion())
+	}
+	return nil
+}
+
+// decodeBinaryStream decodes the file and returns a reader that passes file schema version and decode limits
+// to creators
+func decodeBinaryStream(reader io.Reader, processor CryptoProcessor, schema FileSchema,
+	limits DecodeLimits) (*schemaReader, error) {
+	f, err := decodeFileStream(reader, processor)
+	if err != nil {
+		return nil, err
+	}
+	err = checkFileSchema(f.header, schema)
+	if err != nil {
+		return nil, err
+	}
+	return &schemaReader{Reader: f.data, source: f.source, version: f.header.Version, limits: limits,
+		headerless: f.headerless}, nil
+}
+
+// checkEOF checks that all data was read by a creator. Reading to the end also authenticates
+// the last segment of encrypted data.
+func (r *schemaReader) checkEOF() error {
+	for _, reader := range []io.Reader{r.Reader, r.source} {
+		var b [1]byte
+		n, err := reader.Read(b[:])
+		if n != 0 {
+			return errors.New("non zero buffer length")
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if err == nil {
+			// readers may return zero bytes without an error
+			_, err = io.ReadAll(reader)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
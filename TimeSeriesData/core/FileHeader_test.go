@@ -0,0 +1,233 @@
+package core
+
+import (
+	"TimeSeriesData/crypto"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+type testSchemaData struct {
+	Id         int
+	version    int
+	headerless bool
+}
+
+func (t testSchemaData) Save(writer io.Writer) error {
+	return binary.Write(writer, binary.LittleEndian, uint32(t.Id))
+}
+
+func (t testSchemaData) FileKind() FileKind {
+	return 1
+}
+
+func (t testSchemaData) SchemaVersion() int {
+	return 2
+}
+
+func newTestSchemaData(reader io.Reader) (testSchemaData, error) {
+	var id uint32
+	err := binary.Read(reader, binary.LittleEndian, &id)
+	return testSchemaData{int(id), SchemaVersion(reader), IsHeaderlessFile(reader)}, err
+}
+
+type testOtherSchemaData struct {
+	testSchemaData
+}
+
+func (t testOtherSchemaData) FileKind() FileKind {
+	return 2
+}
+
+func TestFileHeader(t *testing.T) {
+	fileName := t.TempDir() + "/test.bin"
+	err := SaveBinary(fileName, nil, testSchemaData{Id: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data[:fileHeaderSize], []byte{0x89, 'T', 'S', 'D', 1, 2, 0, 0}) {
+		t.Fatalf("unexpected file header %v", data[:fileHeaderSize])
+	}
+	loaded, err := LoadBinary(fileName, nil, newTestSchemaData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Id != 5 || loaded.version != 2 {
+		t.Fatalf("unexpected data %v", loaded)
+	}
+	// files without the header
+	loaded, err = LoadBinaryData(data[fileHeaderSize:], nil, newTestSchemaData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Id != 5 || loaded.version != 1 {
+		t.Fatalf("unexpected data %v", loaded)
+	}
+	_, err = LoadBinary(fileName, nil, func(reader io.Reader) (testOtherSchemaData, error) {
+		v, err := newTestSchemaData(reader)
+		return testOtherSchemaData{v}, err
+	})
+	if !isDecodeError(err, "unexpected file kind 1, expected 2") {
+		t.Fatalf("file kind should be checked: %v", err)
+	}
+	data[5] = 3
+	_, err = LoadBinaryData(data, nil, newTestSchemaData)
+	if !isDecodeError(err, "unsupported schema version 3, latest supported version is 2") {
+		t.Fatalf("schema version should be checked: %v", err)
+	}
+}
+
+func TestEncryptedAndCompressedFile(t *testing.T) {
+	processor, err := crypto.NewAesGcm(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := []byte{1, 0, 0, 0}
+	_, err = EncodeFile(FileHeader{Kind: 1, Version: 2}, processor, source)
+	if err == nil {
+		t.Fatal("encrypted flag should be checked")
+	}
+	data, err := EncodeFile(FileHeader{Kind: 1, Version: 2, Flags: FileEncrypted | FileCompressed}, processor, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBinaryData(data, processor, newTestSchemaData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Id != 1 || loaded.version != 2 {
+		t.Fatalf("unexpected data %v", loaded)
+	}
+	_, err = LoadBinaryData(data, nil, newTestSchemaData)
+	if !isDecodeError(err, "file is encrypted") {
+		t.Fatalf("encrypted flag should be checked: %v", err)
+	}
+	data, err = EncodeFile(FileHeader{Kind: 1, Version: 2}, nil, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadBinaryData(data, processor, newTestSchemaData)
+	if !isDecodeError(err, "file is not encrypted") {
+		t.Fatalf("encrypted flag should be checked: %v", err)
+	}
+}
+
+func TestDecodeFileLimit(t *testing.T) {
+	limits := DefaultDecodeLimits
+	defer func() { DefaultDecodeLimits = limits }()
+	DefaultDecodeLimits.MaxTotalBytes = 100
+	for _, test := range []struct {
+		length   int
+		limitHit bool
+	}{
+		{100, false},
+		{101, true},
+	} {
+		data, err := EncodeFile(FileHeader{Kind: 1, Version: 2, Flags: FileCompressed}, nil, make([]byte, test.length))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, decoded, err := DecodeFile(data, nil)
+		if errors.Is(err, ErrDecodeLimit) != test.limitHit {
+			t.Fatalf("unexpected error for %v bytes: %v", test.length, err)
+		}
+		if !test.limitHit && len(decoded) != test.length {
+			t.Fatalf("unexpected decoded length %v", len(decoded))
+		}
+	}
+}
+
+func TestSegmentedFile(t *testing.T) {
+	processor, err := crypto.NewAesGcm(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := t.TempDir() + "/test.bin"
+	err = SaveBinary(fileName, processor, testSchemaData{Id: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[7] != FileEncrypted|FileSegmented {
+		t.Fatalf("unexpected file flags %v", data[7])
+	}
+	loaded, err := LoadBinary(fileName, processor, newTestSchemaData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Id != 7 || loaded.version != 2 || loaded.headerless {
+		t.Fatalf("unexpected data %v", loaded)
+	}
+	// single blob files
+	data, err = EncodeFile(FileHeader{Kind: 1, Version: 2, Flags: FileEncrypted}, processor, []byte{7, 0, 0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = LoadBinaryStream(bytes.NewReader(data), processor, newTestSchemaData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Id != 7 {
+		t.Fatalf("unexpected data %v", loaded)
+	}
+	// files without the header
+	loaded, err = LoadBinaryData(processor.Encrypt([]byte{7, 0, 0, 0}), processor, newTestSchemaData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Id != 7 || loaded.version != 1 || !loaded.headerless {
+		t.Fatalf("unexpected data %v", loaded)
+	}
+	// extra data
+	data, err = EncodeFile(FileHeader{Kind: 1, Version: 2, Flags: FileEncrypted | FileSegmented | FileCompressed},
+		processor, []byte{7, 0, 0, 0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadBinaryData(data, processor, newTestSchemaData)
+	if !isDecodeError(err, "non zero buffer length") {
+		t.Fatalf("extra data should be detected: %v", err)
+	}
+	// truncated data
+	data, err = EncodeFile(FileHeader{Kind: 1, Version: 2, Flags: FileEncrypted | FileSegmented}, processor,
+		[]byte{7, 0, 0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadBinaryData(data[:len(data)-1], processor, newTestSchemaData)
+	if err == nil {
+		t.Fatal("truncated file should be rejected")
+	}
+}
+
+func FuzzDecodeFile(f *testing.F) {
+	processor, err := crypto.NewAesGcm(make([]byte, 32))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, flags := range []uint8{0, FileCompressed, FileEncrypted, FileEncrypted | FileSegmented | FileCompressed} {
+		var p CryptoProcessor
+		if flags&FileEncrypted != 0 {
+			p = processor
+		}
+		data, err := EncodeFile(FileHeader{Kind: 1, Version: 2, Flags: flags}, p, []byte("data"))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _, _ = DecodeFile(data, nil)
+		_, _, _ = DecodeFile(data, processor)
+	})
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	folder := t.TempDir()
+	fileName := folder + "/test.bin"
+	err := WriteFileAtomic(fileName, []byte{1, 2, 3}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WriteFileAtomic(fileName, []byte{4, 5}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, []byte{4, 5}) {
+		t.Fatal("different data")
+	}
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatal("temporary file should be removed")
+	}
+}
+
+func TestRemoveTempFiles(t *testing.T) {
+	folder := t.TempDir()
+	err := os.Mkdir(folder+"/2020", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/1.bin", "/.1.bin.tmp", "/2020/2.bin", "/2020/.2.bin.tmp"} {
+		err = os.WriteFile(folder+name, []byte{1}, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = RemoveFolderTempFiles(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(folder + "/2020/.2.bin.tmp"); err != nil {
+		t.Fatal("subfolders should be skipped")
+	}
+	err = RemoveTempFiles(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/1.bin", "/2020/2.bin"} {
+		if _, err = os.Stat(folder + name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"/.1.bin.tmp", "/2020/.2.bin.tmp"} {
+		if _, err = os.Stat(folder + name); !os.IsNotExist(err) {
+			t.Fatalf("%v should be removed", name)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/*
+
+Write-ahead journal file structure:
+|magic - 4 bytes|format version - 2 bytes|record length - 4 bytes|record|record length - 4 bytes|record|...
+
+Record structure (encrypted when CryptoProcessor is set):
+|item index - 4 bytes|item date - 4 bytes|record type - 1 byte|item data encoded with JournalCodec|
+
+Record types: 0 - item data, 1 - item removal (no item data).
+Records are appended in modification order, so the last record for an index contains the latest item state.
+A partially written record at the end of the file is ignored and truncated. A complete record that cannot be
+decrypted or decoded is an error, the journal file is left unchanged in this case. Journals without the header
+were written in an older record format and are rejected.
+
+*/
+
+const journalFileName = ".journal"
+
+var journalMagic = []byte{0x89, 'T', 'S', 'J'}
+
+const (
+	journalVersion    = 1
+	journalHeaderSize = 6
+)
+
+const (
+	journalItemRecord    uint8 = 0
+	journalRemovalRecord uint8 = 1
+)
+
+// JournalCodec encodes TimeSeriesData items for the write-ahead journal
+type JournalCodec[T any] interface {
+	Marshal(data *T) ([]byte, error)
+	Unmarshal(data []byte) (*T, error)
+}
+
+type binaryJournalCodec[T any] struct {
+	creator func(reader io.Reader) (*T, error)
+}
+
+// NewBinaryJournalCodec creates JournalCodec for items which pointers implement BinaryData
+func NewBinaryJournalCodec[T any](creator func(reader io.Reader) (*T, error)) JournalCodec[T] {
+	return binaryJournalCodec[T]{creator: creator}
+}
+
+func (c binaryJournalCodec[T]) Marshal(data *T) ([]byte, error) {
+	bdata, ok := any(data).(BinaryData)
+	if !ok {
+		return nil, errors.New("unsupported data type")
+	}
+	// the file header keeps schema version of the record data
+	return buildBinaryFileBytes(nil, bdata)
+}
+
+func (c binaryJournalCodec[T]) Unmarshal(data []byte) (*T, error) {
+	return LoadBinaryDataP(data, nil, c.creator)
+}
+
+type journalRecord struct {
+	index   int
+	date    int
+	removed bool
+	data    []byte
+}
+
+type journal struct {
+	file      *os.File
+	processor CryptoProcessor
+	// size of journal records
+	size int64
+}
+
+// openJournal opens journal file and reads all complete records from it
+func openJournal(fileName string, processor CryptoProcessor) (*journal, []journalRecord, error) {
+	file, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	// makes the entry of a created journal durable
+	err = syncDir(filepath.Dir(fileName))
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	j := &journal{file: file, processor: processor}
+	records, err := j.read()
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	return j, records, nil
+}
+
+// read reads journal records, drops a partially written record at the end of the file and writes the header
+// to a new journal
+func (j *journal) read() ([]journalRecord, error) {
+	data, err := io.ReadAll(j.file)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < journalHeaderSize && bytes.HasPrefix(journalHeader(), data) {
+		// new journal or partially written header
+		return nil, j.truncate()
+	}
+	if len(data) < journalHeaderSize || !bytes.Equal(data[:len(journalMagic)], journalMagic) {
+		return nil, errors.New("journal has no header, it was written by an older version")
+	}
+	version := binary.LittleEndian.Uint16(data[len(journalMagic):])
+	if version != journalVersion {
+		return nil, fmt.Errorf("unsupported journal version %v", version)
+	}
+	records, size, err := parseJournal(data[journalHeaderSize:], j.processor)
+	if err != nil {
+		return nil, err
+	}
+	j.size = size
+	err = j.file.Truncate(journalHeaderSize + size)
+	if err == nil {
+		_, err = j.file.Seek(journalHeaderSize+size, io.SeekStart)
+	}
+	return records, err
+}
+
+func journalHeader() []byte {
+	return binary.LittleEndian.AppendUint16(bytes.Clone(journalMagic), journalVersion)
+}
+
+// parseJournal returns complete records and their total size, a partially written record at the end of data
+// is ignored
+func parseJournal(data []byte, processor CryptoProcessor) ([]journalRecord, int64, error) {
+	var records []journalRecord
+	var size int64
+	for len(data) >= 4 {
+		l := int(binary.LittleEndian.Uint32(data))
+		if len(data) < l+4 {
+			break
+		}
+		recordData := data[4 : l+4]
+		if processor != nil {
+			var err error
+			recordData, err = processor.Decrypt(recordData)
+			if err != nil {
+				return nil, 0, fmt.Errorf("journal record at offset %v: %w", size, err)
+			}
+		}
+		if len(recordData) < 9 {
+			return nil, 0, fmt.Errorf("journal record at offset %v is too short", size)
+		}
+		records = append(records, journalRecord{
+			index:   int(int32(binary.LittleEndian.Uint32(recordData))),
+			date:    int(binary.LittleEndian.Uint32(recordData[4:])),
+			removed: recordData[8] == journalRemovalRecord,
+			data:    recordData[9:],
+		})
+		data = data[l+4:]
+		size += int64(l + 4)
+	}
+	return records, size, nil
+}
+
+// append writes records to the journal and flushes them to the disk
+func (j *journal) append(records []journalRecord) error {
+	buffer := new(bytes.Buffer)
+	for _, r := range records {
+		recordData := binary.LittleEndian.AppendUint32(nil, uint32(int32(r.index)))
+		recordData = binary.LittleEndian.AppendUint32(recordData, uint32(r.date))
+		if r.removed {
+			recordData = append(recordData, journalRemovalRecord)
+		} else {
+			recordData = append(recordData, journalItemRecord)
+		}
+		recordData = append(recordData, r.data...)
+		if j.processor != nil {
+			recordData = j.processor.Encrypt(recordData)
+		}
+		_ = binary.Write(buffer, binary.LittleEndian, uint32(len(recordData)))
+		buffer.Write(recordData)
+	}
+	n, err := j.file.Write(buffer.Bytes())
+	j.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// truncate removes all records and writes the header again
+func (j *journal) truncate() error {
+	err := j.file.Truncate(0)
+	if err != nil {
+		return err
+	}
+	j.size = 0
+	_, err = j.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	_, err = j.file.Write(journalHeader())
+	if err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+func (j *journal) close() error {
+	return j.file.Close()
+}
@@ -0,0 +1,331 @@
+package core
+
+import (
+	"TimeSeriesData/crypto"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testValue struct {
+	Value int
+}
+
+func (v *testValue) Save(writer io.Writer) error {
+	return binary.Write(writer, binary.LittleEndian, int32(v.Value))
+}
+
+func newTestValue(reader io.Reader) (*testValue, error) {
+	var v int32
+	err := binary.Read(reader, binary.LittleEndian, &v)
+	return &testValue{int(v)}, err
+}
+
+type fileDatedSource struct {
+	processor CryptoProcessor
+}
+
+func (s fileDatedSource) GetFileDate(fileName string, _ string) (int, error) {
+	return strconv.Atoi(strings.TrimSuffix(fileName, ".bin"))
+}
+
+func (s fileDatedSource) Load(files []FileWithDate) (*testValue, error) {
+	return LoadBinaryP[testValue](files[0].FileName, s.processor, newTestValue)
+}
+
+func (s fileDatedSource) getFileName(date int, dataFolderPath string) string {
+	return dataFolderPath + "/" + strconv.Itoa(date) + ".bin"
+}
+
+func (s fileDatedSource) GetFiles(date int, dataFolderPath string) ([]FileWithDate, error) {
+	return []FileWithDate{{FileName: s.getFileName(date, dataFolderPath), Date: date}}, nil
+}
+
+func (s fileDatedSource) Save(date int, data *testValue, dataFolderPath string) error {
+	return SaveBinary(s.getFileName(date, dataFolderPath), s.processor, data)
+}
+
+func (s fileDatedSource) Delete(date int, dataFolderPath string) error {
+	err := os.Remove(s.getFileName(date, dataFolderPath))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func initTestValues(t *testing.T, folder string, processor CryptoProcessor) *TimeSeriesData[testValue] {
+	data, err := InitTimeSeriesData[testValue](folder, fileDatedSource{processor}, 100,
+		func(date int) int { return date }, func(date int) int { return date }, 100,
+		WithJournal[testValue](processor, NewBinaryJournalCodec(newTestValue)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func checkTestValue(t *testing.T, data *TimeSeriesData[testValue], date, value int) {
+	v, err := data.GetExact(date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v == nil || v.Value != value {
+		t.Fatalf("wrong value for date %v", date)
+	}
+}
+
+func TestJournalReplay(t *testing.T) {
+	key := make([]byte, 32)
+	processor, err := crypto.NewAesGcm(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder := t.TempDir()
+	data := initTestValues(t, folder, processor)
+	err = data.Add(1, 1, &testValue{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.MarkAsModified(1)
+	err = data.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := data.GetExact(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Value = 10
+	data.MarkAsModified(1)
+	err = data.Add(2, 2, &testValue{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.MarkAsModified(2)
+	err = data.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// not committed change
+	v.Value = 100
+	data.MarkAsModified(1)
+	// simulates crash: data is not saved
+	_ = data.Close()
+	f, err := os.OpenFile(folder+"/"+journalFileName, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// partially written record
+	_, err = f.Write([]byte{100, 0, 0, 0, 1, 2, 3})
+	_ = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data = initTestValues(t, folder, processor)
+	checkTestValue(t, data, 1, 10)
+	checkTestValue(t, data, 2, 2)
+	_ = data.Close()
+	info, err := os.Stat(folder + "/" + journalFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != journalHeaderSize {
+		t.Fatal("journal should be truncated after checkpoint")
+	}
+
+	// replayed items should be saved
+	data = initTestValues(t, folder, processor)
+	checkTestValue(t, data, 1, 10)
+	checkTestValue(t, data, 2, 2)
+	_ = data.Close()
+}
+
+func TestJournalDecryptError(t *testing.T) {
+	key := make([]byte, 32)
+	processor, err := crypto.NewAesGcm(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder := t.TempDir()
+	data := initTestValues(t, folder, processor)
+	for i := 1; i <= 2; i++ {
+		err = data.Add(i, i, &testValue{i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data.MarkAsModified(i)
+		err = data.Commit()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// simulates crash: data is not saved
+	_ = data.Close()
+	fileName := folder + "/" + journalFileName
+	journalData, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key[0] = 1
+	wrongProcessor, err := crypto.NewAesGcm(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = InitTimeSeriesData[testValue](folder, fileDatedSource{wrongProcessor}, 100,
+		func(date int) int { return date }, func(date int) int { return date }, 100,
+		WithJournal[testValue](wrongProcessor, NewBinaryJournalCodec(newTestValue)))
+	if err == nil {
+		t.Fatal("journal decrypt error expected")
+	}
+	// corrupted first record
+	corrupted := slices.Clone(journalData)
+	corrupted[10]++
+	err = os.WriteFile(fileName, corrupted, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = InitTimeSeriesData[testValue](folder, fileDatedSource{processor}, 100,
+		func(date int) int { return date }, func(date int) int { return date }, 100,
+		WithJournal[testValue](processor, NewBinaryJournalCodec(newTestValue)))
+	if err == nil {
+		t.Fatal("journal decrypt error expected")
+	}
+	saved, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(saved, corrupted) {
+		t.Fatal("journal should not be changed")
+	}
+	err = os.WriteFile(fileName, journalData, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = initTestValues(t, folder, processor)
+	checkTestValue(t, data, 1, 1)
+	checkTestValue(t, data, 2, 2)
+	_ = data.Close()
+}
+
+func TestJournalDecodeError(t *testing.T) {
+	folder := t.TempDir()
+	fileName := folder + "/" + journalFileName
+	record := []byte{1, 0, 0, 0, 1, 0, 0, 0, journalItemRecord, 1}
+	records := append(binary.LittleEndian.AppendUint32(nil, uint32(len(record))), record...)
+	// journal without the header and journal with a record that cannot be decoded
+	for _, test := range []struct {
+		journalData []byte
+		message     string
+	}{
+		{records, "older version"},
+		{append(journalHeader(), records...), "journal record of index 1"},
+	} {
+		err := os.WriteFile(fileName, test.journalData, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = InitTimeSeriesData[testValue](folder, fileDatedSource{}, 100,
+			func(date int) int { return date }, func(date int) int { return date }, 100,
+			WithJournal[testValue](nil, NewBinaryJournalCodec(newTestValue)))
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Fatalf("journal error expected: %v", err)
+		}
+		saved, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(saved, test.journalData) {
+			t.Fatal("journal should not be changed")
+		}
+	}
+}
+
+func TestFlusherWithJournal(t *testing.T) {
+	folder := t.TempDir()
+	data := initTestValues(t, folder, nil)
+	for i := 1; i <= 10; i++ {
+		err := data.Add(i, i, &testValue{i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data.MarkAsModified(i)
+	}
+	err := data.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// simulates crash: data is not saved
+	_ = data.Close()
+	// the flusher starts after the journal is replayed
+	data, err = InitTimeSeriesData[testValue](folder, fileDatedSource{}, 100,
+		func(date int) int { return date }, func(date int) int { return date }, 100,
+		WithJournal[testValue](nil, NewBinaryJournalCodec(newTestValue)),
+		WithFlusher[testValue](time.Microsecond, 0, nil, func(err error) { t.Error(err) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 10; i++ {
+		checkTestValue(t, data, i, i)
+	}
+	if data.journal.size != 0 {
+		t.Fatal("journal should be truncated after replay")
+	}
+	// an idle flusher does not touch the journal, so it does not fail after the journal file is closed
+	_ = data.journal.file.Close()
+	err = data.Flush(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	_ = data.Close()
+}
+
+func TestJournalReplaysRemoval(t *testing.T) {
+	folder := t.TempDir()
+	data := initTestValues(t, folder, nil)
+	for i := 1; i <= 2; i++ {
+		err := data.Add(i, i, &testValue{i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data.MarkAsModified(i)
+	}
+	err := data.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = data.Remove(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// simulates crash: data is not saved
+	_ = data.Close()
+
+	data = initTestValues(t, folder, nil)
+	v, err := data.GetExact(1)
+	if err != nil || v != nil {
+		t.Fatal("removed item should not be replayed")
+	}
+	checkTestValue(t, data, 2, 2)
+	_ = data.Close()
+}
+
+func FuzzParseJournal(f *testing.F) {
+	record := binary.LittleEndian.AppendUint32(nil, 1)
+	record = binary.LittleEndian.AppendUint32(record, 20200101)
+	record = append(record, journalItemRecord, 1, 2)
+	data := binary.LittleEndian.AppendUint32(nil, uint32(len(record)))
+	f.Add(append(data, record...))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, size, err := parseJournal(data, nil)
+		if err == nil && size > int64(len(data)) {
+			t.Fatalf("size %v is greater than data length %v", size, len(data))
+		}
+	})
+}
@@ -4,24 +4,26 @@ type LruItem[T any] struct {
 	Data *T
 	Key  int
 	Date int
+	// estimated size of Data in bytes
+	size int
 	next *LruItem[T]
 	prev *LruItem[T]
+	// closed when item loading is finished
+	loading chan struct{}
 }
 
 func NewLruItem[T any](key, date int) *LruItem[T] {
 	return &LruItem[T]{Key: key, Date: date}
 }
 
+// LruManager is the least recently used EvictionPolicy
 type LruManager[T any] struct {
-	head        *LruItem[T]
-	tail        *LruItem[T]
-	activeItems int
+	head *LruItem[T]
+	tail *LruItem[T]
 }
 
-func (m *LruManager[T]) Add(key, date int, data *T) *LruItem[T] {
-	i := &LruItem[T]{data, key, date, nil, m.head}
-	m.Attach(i)
-	return i
+func (m *LruManager[T]) Touch(item *LruItem[T]) {
+	m.MoveToFront(item)
 }
 
 func (m *LruManager[T]) MoveToFront(item *LruItem[T]) {
@@ -41,7 +43,6 @@ func (m *LruManager[T]) Attach(item *LruItem[T]) {
 		m.tail = item
 	}
 	m.head = item
-	m.activeItems++
 }
 
 func (m *LruManager[T]) Detach(item *LruItem[T]) {
@@ -55,7 +56,10 @@ func (m *LruManager[T]) Detach(item *LruItem[T]) {
 	} else {
 		m.tail = item.prev
 	}
-	m.activeItems--
+}
+
+func (m *LruManager[T]) Victim() *LruItem[T] {
+	return m.tail
 }
 
 func (m *LruManager[T]) GetTail() *LruItem[T] {
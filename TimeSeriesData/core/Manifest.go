@@ -0,0 +1,398 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+/*
+
+Manifest file structure:
+|magic - 4 bytes|version - 2 bytes|folders count - 4 bytes|folder|...|entries count - 4 bytes|entry|entry|...|
+|CRC32 of all previous bytes - 4 bytes|
+
+Folder structure:
+|subfolder name - string|subfolder modification time, unix nanoseconds - 8 bytes|
+
+Entry structure:
+|file date - 4 bytes|file name relative to the data folder - string|file size - 8 bytes|file CRC32 - 4 bytes|
+|file modification time, unix nanoseconds - 8 bytes|
+
+The manifest is removed before the first file change and written again when a batch of changes is completed,
+so a missing manifest means that files may have been changed after it was written.
+At startup the manifest is trusted when the data folder has the same subfolders and files and subfolder
+modification times match, so files are not listed or checked one by one. Files changed in place are found by Verify.
+
+*/
+
+const manifestFileName = ".manifest"
+
+var manifestMagic = []byte{0x89, 'T', 'S', 'M'}
+
+const manifestVersion = 1
+
+// manifestFolder describes a subfolder of the data folder
+type manifestFolder struct {
+	Name    string
+	ModTime int64
+}
+
+// ManifestEntry describes a data file of TimeSeriesData
+type ManifestEntry struct {
+	Date     int
+	FileName string
+	Size     int64
+	Checksum uint32
+	ModTime  int64
+}
+
+func getManifestFileName(dataFolderPath string) string {
+	return dataFolderPath + "/" + manifestFileName
+}
+
+// NewManifestEntry reads the file and calculates its checksum
+func NewManifestEntry(dataFolderPath string, file FileWithDate) (ManifestEntry, error) {
+	f, err := os.Open(file.FileName)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	defer func() { _ = f.Close() }()
+	hash := crc32.NewIEEE()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	name, err := filepath.Rel(dataFolderPath, file.FileName)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	return ManifestEntry{Date: file.Date, FileName: filepath.ToSlash(name), Size: info.Size(),
+		Checksum: hash.Sum32(), ModTime: info.ModTime().UnixNano()}, nil
+}
+
+// GetFilePath returns full file name
+func (e ManifestEntry) GetFilePath(dataFolderPath string) string {
+	return dataFolderPath + "/" + e.FileName
+}
+
+// IsStale checks that the file exists and its size and modification time match the entry
+func (e ManifestEntry) IsStale(dataFolderPath string) bool {
+	info, err := os.Stat(e.GetFilePath(dataFolderPath))
+	return err != nil || info.Size() != e.Size || info.ModTime().UnixNano() != e.ModTime
+}
+
+// LoadManifest reads manifest entries from the data folder, os.ErrNotExist is returned when there is no manifest
+func LoadManifest(dataFolderPath string) ([]ManifestEntry, error) {
+	_, entries, err := loadManifest(dataFolderPath)
+	return entries, err
+}
+
+func loadManifest(dataFolderPath string) ([]manifestFolder, []ManifestEntry, error) {
+	data, err := os.ReadFile(getManifestFileName(dataFolderPath))
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeManifest(data)
+}
+
+func decodeManifest(data []byte) ([]manifestFolder, []ManifestEntry, error) {
+	if len(data) < len(manifestMagic)+6 {
+		return nil, nil, errors.New("manifest file is too short")
+	}
+	l := len(data) - 4
+	if crc32.ChecksumIEEE(data[:l]) != binary.LittleEndian.Uint32(data[l:]) {
+		return nil, nil, errors.New("manifest checksum mismatch")
+	}
+	if !bytes.Equal(data[:len(manifestMagic)], manifestMagic) {
+		return nil, nil, errors.New("manifest has no header, it was written by an older version")
+	}
+	version := binary.LittleEndian.Uint16(data[len(manifestMagic):])
+	if version != manifestVersion {
+		return nil, nil, fmt.Errorf("unsupported manifest version %v", version)
+	}
+	reader := NewDecodeReader(bytes.NewReader(data[len(manifestMagic)+2:l]), DefaultDecodeLimits)
+	folders, err := readManifestArray(reader, readManifestFolder)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := readManifestArray(reader, readManifestEntry)
+	return folders, entries, err
+}
+
+func readManifestArray[V any](reader io.Reader, read func(io.Reader) (V, error)) ([]V, error) {
+	var count uint32
+	err := binary.Read(reader, binary.LittleEndian, &count)
+	if err == nil {
+		err = CheckArrayLength(reader, int(count))
+	}
+	if err != nil {
+		return nil, NewDecodeError(reader, manifestFileName, err)
+	}
+	var result []V
+	for range count {
+		var v V
+		v, err = read(reader)
+		if err != nil {
+			return nil, NewDecodeError(reader, manifestFileName, err)
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+func readManifestFolder(reader io.Reader) (manifestFolder, error) {
+	name, err := ReadStringFromBinary(reader)
+	if err != nil {
+		return manifestFolder{}, err
+	}
+	f := manifestFolder{Name: name}
+	err = binary.Read(reader, binary.LittleEndian, &f.ModTime)
+	return f, err
+}
+
+func readManifestEntry(reader io.Reader) (ManifestEntry, error) {
+	var date uint32
+	err := binary.Read(reader, binary.LittleEndian, &date)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	name, err := ReadStringFromBinary(reader)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	e := ManifestEntry{Date: int(date), FileName: name}
+	err = binary.Read(reader, binary.LittleEndian, &e.Size)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	err = binary.Read(reader, binary.LittleEndian, &e.Checksum)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	err = binary.Read(reader, binary.LittleEndian, &e.ModTime)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	return e, nil
+}
+
+// SaveManifest writes manifest entries and modification times of data subfolders to the data folder
+func SaveManifest(dataFolderPath string, entries []ManifestEntry) error {
+	_, folders, err := readDataFolder(dataFolderPath)
+	if err != nil {
+		return err
+	}
+	buffer := bytes.NewBuffer(binary.LittleEndian.AppendUint16(bytes.Clone(manifestMagic), manifestVersion))
+	_ = binary.Write(buffer, binary.LittleEndian, uint32(len(folders)))
+	for _, f := range folders {
+		err = WriteStringToBinary(buffer, f.Name)
+		if err != nil {
+			return err
+		}
+		_ = binary.Write(buffer, binary.LittleEndian, f.ModTime)
+	}
+	_ = binary.Write(buffer, binary.LittleEndian, uint32(len(entries)))
+	for _, e := range entries {
+		_ = binary.Write(buffer, binary.LittleEndian, uint32(e.Date))
+		err := WriteStringToBinary(buffer, e.FileName)
+		if err != nil {
+			return err
+		}
+		_ = binary.Write(buffer, binary.LittleEndian, e.Size)
+		_ = binary.Write(buffer, binary.LittleEndian, e.Checksum)
+		_ = binary.Write(buffer, binary.LittleEndian, e.ModTime)
+	}
+	_ = binary.Write(buffer, binary.LittleEndian, crc32.ChecksumIEEE(buffer.Bytes()))
+	return WriteFileAtomic(getManifestFileName(dataFolderPath), buffer.Bytes(), 0644)
+}
+
+// RemoveManifest removes the manifest from the data folder, missing manifest is not an error
+func RemoveManifest(dataFolderPath string) error {
+	err := os.Remove(getManifestFileName(dataFolderPath))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// readManifest returns manifest entries by item index or nil when the manifest is missing or broken, or when
+// files were added to or removed from the data folder after it was written
+func (t *TimeSeriesData[T]) readManifest() map[int][]ManifestEntry {
+	savedFolders, entries, err := loadManifest(t.dataFolderPath)
+	if err != nil {
+		return nil
+	}
+	// subfolder modification times change when files are added, removed or replaced by atomic writes
+	files, folders, err := readDataFolder(t.dataFolderPath)
+	if err != nil || !slices.Equal(folders, savedFolders) {
+		return nil
+	}
+	result := make(map[int][]ManifestEntry)
+	for _, e := range entries {
+		if !strings.Contains(e.FileName, "/") {
+			if !files[e.FileName] {
+				return nil
+			}
+			delete(files, e.FileName)
+		}
+		idx := t.IndexCalculator(e.Date)
+		result[idx] = append(result[idx], e)
+	}
+	if len(files) != 0 {
+		return nil
+	}
+	return result
+}
+
+// readDataFolder returns names of files and sorted subfolders of the data folder, it skips service files the same
+// way as getFileList
+func readDataFolder(dataFolderPath string) (map[string]bool, []manifestFolder, error) {
+	entries, err := os.ReadDir(dataFolderPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	files := make(map[string]bool)
+	var folders []manifestFolder
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if !entry.IsDir() {
+			files[entry.Name()] = true
+			continue
+		}
+		var info os.FileInfo
+		info, err = entry.Info()
+		if err != nil {
+			return nil, nil, err
+		}
+		folders = append(folders, manifestFolder{Name: entry.Name(), ModTime: info.ModTime().UnixNano()})
+	}
+	return files, folders, nil
+}
+
+// listFiles returns data files from the manifest. When the manifest is missing or stale, it scans the data folder
+// and writes a new manifest.
+func (t *TimeSeriesData[T]) listFiles() ([]FileWithDate, error) {
+	manifest := t.readManifest()
+	if manifest == nil {
+		files, err := t.getFileList("")
+		if err != nil {
+			return nil, err
+		}
+		manifest = make(map[int][]ManifestEntry)
+		for _, f := range files {
+			var e ManifestEntry
+			e, err = NewManifestEntry(t.dataFolderPath, f)
+			if err != nil {
+				return nil, err
+			}
+			idx := t.IndexCalculator(f.Date)
+			manifest[idx] = append(manifest[idx], e)
+		}
+		t.manifest = manifest
+		t.manifestChanged = true
+		err = t.saveManifest()
+		if err != nil {
+			return nil, err
+		}
+		return files, nil
+	}
+	t.manifest = manifest
+	var files []FileWithDate
+	for _, entries := range manifest {
+		for _, e := range entries {
+			files = append(files, FileWithDate{FileName: e.GetFilePath(t.dataFolderPath), Date: e.Date})
+		}
+	}
+	return files, nil
+}
+
+// invalidateManifest removes the manifest file before the first file change after the manifest was saved
+func (t *TimeSeriesData[T]) invalidateManifest(dataFolderPath string) error {
+	if t.manifest == nil || dataFolderPath != t.dataFolderPath || t.manifestChanged {
+		return nil
+	}
+	err := RemoveManifest(t.dataFolderPath)
+	if err != nil {
+		return err
+	}
+	t.manifestChanged = true
+	return nil
+}
+
+// updateManifest replaces manifest entries of an item with its current files
+func (t *TimeSeriesData[T]) updateManifest(k, date int, dataFolderPath string) error {
+	if t.manifest == nil || dataFolderPath != t.dataFolderPath {
+		return nil
+	}
+	files, err := t.source.GetFiles(date, t.dataFolderPath)
+	if err != nil {
+		return err
+	}
+	var entries []ManifestEntry
+	for _, f := range files {
+		var e ManifestEntry
+		e, err = NewManifestEntry(t.dataFolderPath, f)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// DatedSource.GetFiles may return dates that differ from GetFileDate results used by listFiles
+		e.Date, err = t.getFileDate(e.FileName)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		delete(t.manifest, k)
+	} else {
+		t.manifest[k] = entries
+	}
+	return nil
+}
+
+// getFileDate calculates the date of a file relative to the data folder the same way as getFileList
+func (t *TimeSeriesData[T]) getFileDate(name string) (int, error) {
+	folder, fileName := path.Split(name)
+	return t.source.GetFileDate(fileName, strings.TrimSuffix(folder, "/"))
+}
+
+// saveManifest writes the manifest when it was changed
+func (t *TimeSeriesData[T]) saveManifest() error {
+	if t.manifest == nil || !t.manifestChanged {
+		return nil
+	}
+	var entries []ManifestEntry
+	for _, v := range t.manifest {
+		entries = append(entries, v...)
+	}
+	slices.SortFunc(entries, func(a, b ManifestEntry) int {
+		if a.Date != b.Date {
+			return a.Date - b.Date
+		}
+		return strings.Compare(a.FileName, b.FileName)
+	})
+	err := SaveManifest(t.dataFolderPath, entries)
+	if err != nil {
+		return err
+	}
+	t.manifestChanged = false
+	return nil
+}
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"testing"
+)
+
+func initManifestTestValues(t *testing.T, folder string) *TimeSeriesData[testValue] {
+	data, err := InitTimeSeriesData[testValue](folder, fileDatedSource{}, 10,
+		func(date int) int { return date }, func(date int) int { return date }, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func checkManifest(t *testing.T, folder string, dates ...int) {
+	entries, err := LoadManifest(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(dates) {
+		t.Fatalf("unexpected manifest entries %v", entries)
+	}
+	for i, e := range entries {
+		if e.Date != dates[i] || e.IsStale(folder) {
+			t.Fatalf("unexpected manifest entry %v", e)
+		}
+		expected, err := NewManifestEntry(folder, FileWithDate{FileName: e.GetFilePath(folder), Date: e.Date})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e != expected {
+			t.Fatalf("wrong manifest entry %v", e)
+		}
+	}
+}
+
+func TestManifest(t *testing.T) {
+	folder := t.TempDir()
+	source := fileDatedSource{}
+	for i := 1; i <= 3; i++ {
+		err := source.Save(i, &testValue{i}, folder)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := initManifestTestValues(t, folder)
+	checkManifest(t, folder, 1, 2, 3)
+
+	err := data.Add(4, 4, &testValue{4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.MarkAsModified(4)
+	err = data.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkManifest(t, folder, 1, 2, 3, 4)
+	err = data.Remove(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkManifest(t, folder, 2, 3, 4)
+
+	// files that are not in the manifest, for example restored from a backup, trigger a rescan
+	err = os.Mkdir(folder+"/2020", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = source.Save(5, &testValue{5}, folder+"/2020")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = initManifestTestValues(t, folder)
+	if minIndex, maxIndex := data.Bounds(); minIndex != 2 || maxIndex != 5 {
+		t.Fatalf("unexpected bounds %v %v", minIndex, maxIndex)
+	}
+	checkTestValue(t, data, 4, 4)
+	checkManifest(t, folder, 2, 3, 4, 5)
+
+	// new files in a subfolder change its modification time
+	err = source.Save(6, &testValue{6}, folder+"/2020")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = initManifestTestValues(t, folder)
+	if _, maxIndex := data.Bounds(); maxIndex != 6 {
+		t.Fatalf("unexpected max index %v", maxIndex)
+	}
+	checkManifest(t, folder, 2, 3, 4, 5, 6)
+
+	// the manifest is trusted when the data folder was not changed, so files are not checked one by one
+	err = os.WriteFile(source.getFileName(3, folder), []byte{30, 0, 0, 0, 0}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.readManifest() == nil {
+		t.Fatal("manifest should be used")
+	}
+}
+
+func TestOldManifestFormat(t *testing.T) {
+	folder := t.TempDir()
+	source := fileDatedSource{}
+	err := source.Save(1, &testValue{1}, folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := binary.LittleEndian.AppendUint32(nil, 0)
+	data = binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+	err = os.WriteFile(getManifestFileName(folder), data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initManifestTestValues(t, folder)
+	checkManifest(t, folder, 1)
+}
+
+func TestStaleManifestRepair(t *testing.T) {
+	folder := t.TempDir()
+	source := fileDatedSource{}
+	for i := 1; i <= 3; i++ {
+		err := source.Save(i, &testValue{i}, folder)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	initManifestTestValues(t, folder)
+	err := os.Remove(source.getFileName(2, folder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = source.Save(4, &testValue{4}, folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := initManifestTestValues(t, folder)
+	if data.GetDate(2) != 0 || data.GetDate(4) != 4 {
+		t.Fatal("stale manifest should be ignored")
+	}
+	checkManifest(t, folder, 1, 3, 4)
+
+	err = os.WriteFile(getManifestFileName(folder), []byte{1, 2, 3, 4, 5, 6, 7, 8}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initManifestTestValues(t, folder)
+	checkManifest(t, folder, 1, 3, 4)
+}
+
+func FuzzDecodeManifest(f *testing.F) {
+	data := binary.LittleEndian.AppendUint16(bytes.Clone(manifestMagic), manifestVersion)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = append(data, 4, 0, '2', '0', '2', '0')
+	data = append(data, make([]byte, 8)...)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = binary.LittleEndian.AppendUint32(data, 20200101)
+	data = append(data, 5, 0, '1', '.', 'b', 'i', 'n')
+	data = append(data, make([]byte, 20)...)
+	f.Add(data)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		data = binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+		_, _, _ = decodeManifest(data)
+	})
+}
+
+func TestDecodeManifestLimits(t *testing.T) {
+	header := binary.LittleEndian.AppendUint16(bytes.Clone(manifestMagic), manifestVersion)
+	data := binary.LittleEndian.AppendUint32(bytes.Clone(header), 0xFFFFFFFF)
+	data = binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+	_, _, err := decodeManifest(data)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.FileName != manifestFileName || decodeErr.Offset != 4 {
+		t.Fatalf("DecodeError expected: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// TimeSeriesDataStats contains TimeSeriesData cache and I/O counters
+type TimeSeriesDataStats struct {
+	// requests to loaded items
+	Hits int64
+	// requests to items that were loaded from disk
+	Misses    int64
+	Loads     int64
+	Evictions int64
+	// modified items written to disk
+	WriteBacks   int64
+	BytesRead    int64
+	BytesWritten int64
+	// total time spent on loads
+	LoadTime    time.Duration
+	ActiveItems int
+	ActiveBytes int
+}
+
+func (s TimeSeriesDataStats) AverageLoadTime() time.Duration {
+	if s.Loads == 0 {
+		return 0
+	}
+	return s.LoadTime / time.Duration(s.Loads)
+}
+
+func (s TimeSeriesDataStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func (s TimeSeriesDataStats) String() string {
+	return fmt.Sprintf("hits: %v\nmisses: %v\nhit ratio: %.2f\nloads: %v\naverage load time: %v\nevictions: %v\n"+
+		"write backs: %v\nbytes read: %v\nbytes written: %v\nactive items: %v\nactive bytes: %v",
+		s.Hits, s.Misses, s.HitRatio(), s.Loads, s.AverageLoadTime(), s.Evictions, s.WriteBacks, s.BytesRead,
+		s.BytesWritten, s.ActiveItems, s.ActiveBytes)
+}
+
+/*
+
+Binary structure:
+|hits - 8 bytes|misses - 8 bytes|loads - 8 bytes|evictions - 8 bytes|write backs - 8 bytes|bytes read - 8 bytes|
+|bytes written - 8 bytes|load time in microseconds - 8 bytes|active items - 8 bytes|active bytes - 8 bytes|
+
+*/
+
+func (s TimeSeriesDataStats) Save(writer io.Writer) error {
+	return binary.Write(writer, binary.LittleEndian, []int64{s.Hits, s.Misses, s.Loads, s.Evictions, s.WriteBacks,
+		s.BytesRead, s.BytesWritten, s.LoadTime.Microseconds(), int64(s.ActiveItems), int64(s.ActiveBytes)})
+}
+
+func filesSize(files []FileWithDate) int64 {
+	var size int64
+	for _, f := range files {
+		info, err := os.Stat(f.FileName)
+		if err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
@@ -0,0 +1,27 @@
+package core
+
+import "testing"
+
+func TestStats(t *testing.T) {
+	folder := t.TempDir()
+	data := NewTimeSeriesData[testValue](folder, fileDatedSource{}, 10,
+		func(date int) int { return date }, func(date int) int { return date }, 2)
+	for i := 1; i <= 3; i++ {
+		err := data.Add(i, i, &testValue{i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data.MarkAsModified(i)
+	}
+	checkTestValue(t, data, 1, 1)
+	checkTestValue(t, data, 1, 1)
+	stats := data.Stats()
+	expected := TimeSeriesDataStats{Hits: 1, Misses: 1, Loads: 1, Evictions: 2, WriteBacks: 2, BytesRead: 12,
+		BytesWritten: 24, LoadTime: stats.LoadTime, ActiveItems: 2}
+	if stats != expected {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats.AverageLoadTime() != stats.LoadTime {
+		t.Fatal("wrong average load time")
+	}
+}
@@ -1,6 +1,20 @@
 package core
 
-import "os"
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTimeSeriesDataSize limits the index space, so a wrong date does not lead to a huge allocation
+const maxTimeSeriesDataSize = 1 << 24
 
 type FileWithDate struct {
 	FileName string
@@ -12,32 +26,157 @@ type DatedSource[T any] interface {
 	Load(files []FileWithDate) (*T, error)
 	GetFiles(date int, dataFolderPath string) ([]FileWithDate, error)
 	Save(date int, data *T, dataFolderPath string) error
+	// Delete removes item files, missing files are not an error
+	Delete(date int, dataFolderPath string) error
+}
+
+// SizeEstimator can be implemented by DatedSource to limit memory used by loaded items, see WithMaxActiveBytes
+type SizeEstimator[T any] interface {
+	EstimateSize(data *T) int
 }
 
+// TimeSeriesData is safe for concurrent readers. Callers should not modify loaded items concurrently with readers.
 type TimeSeriesData[T any] struct {
+	// guards data, base, minIndex, maxIndex, keys, policy, activeItems, activeBytes, modified, pending, stats
+	// and manifest
+	lock           sync.Mutex
 	dataFolderPath string
 	source         DatedSource[T]
 	// calculates array index from file date
 	IndexCalculator func(int) int
 	// calculates file date from date yyyymmdd
 	DateCalculator func(int) int
-	data           []*LruItem[T]
-	maxIndex       int
+	// data[0] contains an item with index base
+	data     []*LruItem[T]
+	base     int
+	minIndex int
+	maxIndex int
+	// sorted indexes of existing items, used by lookups over gaps
+	keys           []int
 	maxActiveItems int
-	lruManager     LruManager[T]
-	modified       map[int]bool
+	maxActiveBytes int
+	estimator      SizeEstimator[T]
+	policy         EvictionPolicy[T]
+	activeItems    int
+	activeBytes    int
+	// modified items and times of their first modification
+	modified map[int]time.Time
+	// modified items that are not written to the journal yet
+	pending          map[int]bool
+	journal          *journal
+	journalProcessor CryptoProcessor
+	journalCodec     JournalCodec[T]
+	stats            TimeSeriesDataStats
+	flushInterval    time.Duration
+	maxDirtyAge      time.Duration
+	flushLocker      sync.Locker
+	onFlushError     func(error)
+	stopFlusher      chan struct{}
+	flusherDone      chan struct{}
+	loadWorkers      int
+	onLoadProgress   func(loaded, total int)
+	// manifest entries by item index, nil when the manifest is not used
+	manifest map[int][]ManifestEntry
+	// manifest entries were changed after the manifest was saved
+	manifestChanged bool
+}
+
+type TimeSeriesDataOption[T any] func(t *TimeSeriesData[T])
+
+// WithEvictionPolicy replaces default least recently used eviction policy
+func WithEvictionPolicy[T any](policy EvictionPolicy[T]) TimeSeriesDataOption[T] {
+	return func(t *TimeSeriesData[T]) {
+		t.policy = policy
+	}
+}
+
+// WithFlusher starts background goroutine that saves items modified more than maxDirtyAge ago every interval.
+// Flusher acquires locker (if not nil) before saving, so callers can exclude concurrent modifications of items.
+// Save errors are reported to onError, items that were not saved stay modified until the next flush.
+func WithFlusher[T any](interval, maxDirtyAge time.Duration, locker sync.Locker,
+	onError func(error)) TimeSeriesDataOption[T] {
+	return func(t *TimeSeriesData[T]) {
+		t.flushInterval = interval
+		t.maxDirtyAge = maxDirtyAge
+		t.flushLocker = locker
+		t.onFlushError = onError
+	}
 }
 
+// WithMaxActiveBytes limits estimated size of loaded items, maxActiveItems still limits their count.
+// Zero value or DatedSource that does not implement SizeEstimator disables the limit.
+func WithMaxActiveBytes[T any](maxActiveBytes int) TimeSeriesDataOption[T] {
+	return func(t *TimeSeriesData[T]) {
+		t.maxActiveBytes = maxActiveBytes
+	}
+}
+
+// WithLoadWorkers limits the number of goroutines that load items in LoadTimeSeriesData,
+// default value is runtime.GOMAXPROCS(0). DatedSource.Load should be safe for concurrent use.
+func WithLoadWorkers[T any](workers int) TimeSeriesDataOption[T] {
+	return func(t *TimeSeriesData[T]) {
+		t.loadWorkers = workers
+	}
+}
+
+// WithLoadProgress sets a callback that LoadTimeSeriesData calls after adding each item
+func WithLoadProgress[T any](onProgress func(loaded, total int)) TimeSeriesDataOption[T] {
+	return func(t *TimeSeriesData[T]) {
+		t.onLoadProgress = onProgress
+	}
+}
+
+// PrintLoadProgress is a WithLoadProgress callback that prints loaded partition count to the console
+func PrintLoadProgress(loaded, total int) {
+	fmt.Printf("\r%v of %v partitions loaded", loaded, total)
+	if loaded == total {
+		fmt.Println()
+	}
+}
+
+// WithJournal enables write-ahead journal that is replayed by LoadTimeSeriesData and InitTimeSeriesData
+func WithJournal[T any](processor CryptoProcessor, codec JournalCodec[T]) TimeSeriesDataOption[T] {
+	return func(t *TimeSeriesData[T]) {
+		t.journalProcessor = processor
+		t.journalCodec = codec
+	}
+}
+
+// NewTimeSeriesData creates empty TimeSeriesData. Capacity is the initial size of the index space,
+// it grows on demand in both directions, so indexes calculated from dates before the minimum date may be negative.
 func NewTimeSeriesData[T any](
 	dataFolderPath string,
 	source DatedSource[T],
 	capacity int,
 	indexCalculator func(int) int,
 	dateCalculator func(int) int,
-	maxActiveItems int) TimeSeriesData[T] {
-	return TimeSeriesData[T]{dataFolderPath: dataFolderPath, source: source, maxIndex: -1, IndexCalculator: indexCalculator,
-		DateCalculator: dateCalculator, data: make([]*LruItem[T], capacity), lruManager: LruManager[T]{},
-		maxActiveItems: maxActiveItems, modified: make(map[int]bool)}
+	maxActiveItems int,
+	options ...TimeSeriesDataOption[T]) *TimeSeriesData[T] {
+	t := newTimeSeriesData(dataFolderPath, source, capacity, indexCalculator, dateCalculator, maxActiveItems,
+		options...)
+	t.startFlusher()
+	return t
+}
+
+// newTimeSeriesData creates empty TimeSeriesData without starting the flusher, so loaders can start it after
+// the data is loaded and the journal is replayed
+func newTimeSeriesData[T any](
+	dataFolderPath string,
+	source DatedSource[T],
+	capacity int,
+	indexCalculator func(int) int,
+	dateCalculator func(int) int,
+	maxActiveItems int,
+	options ...TimeSeriesDataOption[T]) *TimeSeriesData[T] {
+	t := &TimeSeriesData[T]{dataFolderPath: dataFolderPath, source: source, maxIndex: -1, IndexCalculator: indexCalculator,
+		DateCalculator: dateCalculator, data: make([]*LruItem[T], capacity), policy: &LruManager[T]{},
+		maxActiveItems: maxActiveItems, modified: make(map[int]time.Time), pending: make(map[int]bool),
+		loadWorkers: runtime.GOMAXPROCS(0)}
+	t.estimator, _ = source.(SizeEstimator[T])
+	for _, option := range options {
+		option(t)
+	}
+	return t
 }
 
 func LoadTimeSeriesData[T any](
@@ -46,8 +185,14 @@ func LoadTimeSeriesData[T any](
 	capacity int,
 	indexCalculator func(int) int,
 	dateCalculator func(int) int,
-	maxActiveItems int) (TimeSeriesData[T], error) {
-	data := NewTimeSeriesData(dataFolderPath, source, capacity, indexCalculator, dateCalculator, maxActiveItems)
+	maxActiveItems int,
+	options ...TimeSeriesDataOption[T]) (*TimeSeriesData[T], error) {
+	data := newTimeSeriesData(dataFolderPath, source, capacity, indexCalculator, dateCalculator, maxActiveItems,
+		options...)
+	err := RemoveTempFiles(dataFolderPath)
+	if err != nil {
+		return data, err
+	}
 	files, err := data.getFileList("")
 	if err != nil {
 		return data, err
@@ -63,19 +208,91 @@ func LoadTimeSeriesData[T any](
 			fileMap[idx] = fileList
 		}
 	}
-	for k, v := range fileMap {
-		var item *T
-		item, err = source.Load(v)
-		if err != nil {
-			return data, err
+	err = data.loadAll(fileMap)
+	if err != nil {
+		return data, err
+	}
+	err = data.openJournal()
+	if err != nil {
+		return data, err
+	}
+	data.startFlusher()
+	return data, nil
+}
+
+type loadResult[T any] struct {
+	data *T
+	done chan struct{}
+}
+
+// loadAll loads file groups using a pool of loadWorkers goroutines and adds items in index order,
+// so the eviction policy state does not depend on load timing. Loading stops on the first error.
+func (t *TimeSeriesData[T]) loadAll(fileMap map[int][]FileWithDate) error {
+	indexes := make([]int, 0, len(fileMap))
+	for k := range fileMap {
+		indexes = append(indexes, k)
+	}
+	slices.Sort(indexes)
+	results := make([]loadResult[T], len(indexes))
+	for i := range results {
+		results[i].done = make(chan struct{})
+	}
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var loadErr error
+	cancel := func(err error) {
+		stopOnce.Do(func() {
+			loadErr = err
+			close(stop)
+		})
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(min(t.loadWorkers, len(indexes)), 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				data, err := t.loadFiles(fileMap[indexes[i]])
+				if err != nil {
+					cancel(err)
+					return
+				}
+				results[i].data = data
+				close(results[i].done)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range indexes {
+			select {
+			case jobs <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	defer func() {
+		cancel(nil)
+		wg.Wait()
+	}()
+	for i, k := range indexes {
+		select {
+		case <-results[i].done:
+		case <-stop:
+			return loadErr
 		}
-		date := dateCalculator(v[0].Date)
-		err = data.Add(k, date, item)
+		err := t.Add(k, t.DateCalculator(fileMap[k][0].Date), results[i].data)
 		if err != nil {
-			return data, err
+			return err
+		}
+		results[i].data = nil
+		if t.onLoadProgress != nil {
+			t.onLoadProgress(i+1, len(indexes))
 		}
 	}
-	return data, nil
+	return nil
 }
 
 func InitTimeSeriesData[T any](
@@ -84,9 +301,15 @@ func InitTimeSeriesData[T any](
 	capacity int,
 	indexCalculator func(int) int,
 	dateCalculator func(int) int,
-	maxActiveItems int) (TimeSeriesData[T], error) {
-	data := NewTimeSeriesData(dataFolderPath, source, capacity, indexCalculator, dateCalculator, maxActiveItems)
-	files, err := data.getFileList("")
+	maxActiveItems int,
+	options ...TimeSeriesDataOption[T]) (*TimeSeriesData[T], error) {
+	data := newTimeSeriesData(dataFolderPath, source, capacity, indexCalculator, dateCalculator, maxActiveItems,
+		options...)
+	err := RemoveTempFiles(dataFolderPath)
+	if err != nil {
+		return data, err
+	}
+	files, err := data.listFiles()
 	if err != nil {
 		return data, err
 	}
@@ -97,25 +320,173 @@ func InitTimeSeriesData[T any](
 		indexes[idx] = date
 	}
 	for idx, date := range indexes {
-		data.set(idx, NewLruItem[T](idx, date))
+		err = data.set(idx, NewLruItem[T](idx, date))
+		if err != nil {
+			return data, err
+		}
 	}
+	err = data.openJournal()
+	if err != nil {
+		return data, err
+	}
+	data.startFlusher()
 	return data, nil
 }
 
-func (t *TimeSeriesData[T]) set(k int, item *LruItem[T]) {
-	if k > t.maxIndex {
+// openJournal replays the journal and saves replayed items
+func (t *TimeSeriesData[T]) openJournal() error {
+	if t.journalCodec == nil {
+		return nil
+	}
+	j, records, err := openJournal(t.dataFolderPath+"/"+journalFileName, t.journalProcessor)
+	if err != nil {
+		return err
+	}
+	t.journal = j
+	if len(records) == 0 {
+		return nil
+	}
+	err = t.replayJournal(records)
+	if err != nil {
+		// the journal file is kept for the next start
+		_ = j.close()
+		t.journal = nil
+	}
+	return err
+}
+
+func (t *TimeSeriesData[T]) replayJournal(records []journalRecord) error {
+	// flusher should not save replayed items until all records are replayed
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, r := range records {
+		if r.removed {
+			err := t.remove(r.index, r.date)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := t.journalCodec.Unmarshal(r.data)
+		if err != nil {
+			return fmt.Errorf("journal record of index %v: %w", r.index, err)
+		}
+		err = t.replay(r.index, r.date, data)
+		if err != nil {
+			return err
+		}
+	}
+	return t.flush(0)
+}
+
+func (t *TimeSeriesData[T]) replay(k, date int, data *T) error {
+	_, _, err := t.indexRange(k)
+	if err != nil {
+		return err
+	}
+	size := t.estimateSize(data)
+	item := t.item(k)
+	if item == nil || item.Data == nil {
+		err = t.cleanup(size)
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			err = t.set(k, t.attach(NewLruItem[T](k, date), data, size))
+			if err != nil {
+				return err
+			}
+		} else {
+			t.attach(item, data, size)
+		}
+	} else {
+		item.Data = data
+		t.resize(item, size)
+		t.policy.Touch(item)
+	}
+	t.markAsModified(k)
+	return nil
+}
+
+func (t *TimeSeriesData[T]) isEmpty() bool {
+	return t.maxIndex < t.minIndex
+}
+
+// item returns an item with index k or nil
+func (t *TimeSeriesData[T]) item(k int) *LruItem[T] {
+	if k < t.minIndex || k > t.maxIndex {
+		return nil
+	}
+	return t.data[k-t.base]
+}
+
+// indexRange returns the index range that includes all items and k
+func (t *TimeSeriesData[T]) indexRange(k int) (int, int, error) {
+	from := k
+	to := k
+	if !t.isEmpty() {
+		from = min(k, t.minIndex)
+		to = max(k, t.maxIndex)
+	}
+	if to-from >= maxTimeSeriesDataSize {
+		return 0, 0, fmt.Errorf("index %v is out of range: TimeSeriesData size is limited to %v items", k,
+			maxTimeSeriesDataSize)
+	}
+	return from, to, nil
+}
+
+func (t *TimeSeriesData[T]) set(k int, item *LruItem[T]) error {
+	from, to, err := t.indexRange(k)
+	if err != nil {
+		return err
+	}
+	if from < t.base || to >= t.base+len(t.data) {
+		t.grow(from, to)
+	}
+	if t.isEmpty() {
+		t.minIndex = k
 		t.maxIndex = k
+	} else {
+		t.minIndex = from
+		t.maxIndex = to
+	}
+	if t.data[k-t.base] == nil {
+		pos, _ := slices.BinarySearch(t.keys, k)
+		t.keys = slices.Insert(t.keys, pos, k)
+	}
+	t.data[k-t.base] = item
+	return nil
+}
+
+// grow reallocates data, so indexes from..to fit into it
+func (t *TimeSeriesData[T]) grow(from, to int) {
+	size := min(max(len(t.data)*2, to-from+1), maxTimeSeriesDataSize)
+	base := from
+	// leaves free space before the first item when growing down
+	if from < t.base {
+		base = to - size + 1
 	}
-	t.data[k] = item
+	data := make([]*LruItem[T], size)
+	if !t.isEmpty() {
+		copy(data[t.minIndex-base:], t.data[t.minIndex-t.base:t.maxIndex-t.base+1])
+	}
+	t.data = data
+	t.base = base
 }
 
 func (t *TimeSeriesData[T]) Add(k, date int, item *T) error {
-	err := t.cleanup()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	_, _, err := t.indexRange(k)
 	if err != nil {
 		return err
 	}
-	t.set(k, t.lruManager.Add(k, date, item))
-	return nil
+	size := t.estimateSize(item)
+	err = t.cleanup(size)
+	if err != nil {
+		return err
+	}
+	return t.set(k, t.attach(NewLruItem[T](k, date), item, size))
 }
 
 func (t *TimeSeriesData[T]) getFileList(folder string) ([]FileWithDate, error) {
@@ -131,6 +502,10 @@ func (t *TimeSeriesData[T]) getFileList(folder string) ([]FileWithDate, error) {
 	}
 	var result []FileWithDate
 	for _, file := range fi {
+		// service files like journal and temporary files
+		if strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
 		if file.Mode().IsDir() {
 			var info []FileWithDate
 			info, err = t.getFileList(file.Name())
@@ -150,169 +525,654 @@ func (t *TimeSeriesData[T]) getFileList(folder string) ([]FileWithDate, error) {
 	return result, nil
 }
 
+// Get is Floor, it is kept for existing callers
 func (t *TimeSeriesData[T]) Get(date int) (int, *T, error) {
+	return t.Floor(date)
+}
+
+// Floor returns the item for the date or the nearest earlier item.
+// When there is no such item it returns the index calculated from the date and nil.
+func (t *TimeSeriesData[T]) Floor(date int) (int, *T, error) {
+	return t.lookup(date, func(keys []int, idx int) int {
+		pos, found := slices.BinarySearch(keys, idx)
+		if found {
+			return pos
+		}
+		return pos - 1
+	})
+}
+
+// Ceiling returns the item for the date or the nearest later item.
+// When there is no such item it returns the index calculated from the date and nil.
+func (t *TimeSeriesData[T]) Ceiling(date int) (int, *T, error) {
+	return t.lookup(date, func(keys []int, idx int) int {
+		pos, _ := slices.BinarySearch(keys, idx)
+		return pos
+	})
+}
+
+// Nearest returns the item with the index closest to the index calculated from the date,
+// the earlier item wins when distances are equal.
+// When there are no items it returns the index calculated from the date and nil.
+func (t *TimeSeriesData[T]) Nearest(date int) (int, *T, error) {
+	return t.lookup(date, func(keys []int, idx int) int {
+		pos, found := slices.BinarySearch(keys, idx)
+		if found || pos == 0 {
+			return pos
+		}
+		if pos == len(keys) || idx-keys[pos-1] <= keys[pos]-idx {
+			return pos - 1
+		}
+		return pos
+	})
+}
+
+// lookup loads an item which position in keys is returned by find, positions out of keys range mean no item
+func (t *TimeSeriesData[T]) lookup(date int, find func(keys []int, idx int) int) (int, *T, error) {
 	idx := t.IndexCalculator(date)
-	if idx < 0 {
+	t.lock.Lock()
+	pos := find(t.keys, idx)
+	if pos < 0 || pos >= len(t.keys) {
+		t.lock.Unlock()
 		return idx, nil, nil
 	}
-	if idx > t.maxIndex {
-		idx = t.maxIndex
-	}
-	for i := idx; i >= 0; idx-- {
-		d := t.data[i]
-		if d != nil {
-			dd, err := t.get(d)
-			return i, dd, err
-		}
-	}
-	return idx, nil, nil
+	k := t.keys[pos]
+	d := t.item(k)
+	t.lock.Unlock()
+	data, err := t.get(d)
+	return k, data, err
 }
 
+// GetExact returns the item for the date or nil when there is no such item
 func (t *TimeSeriesData[T]) GetExact(date int) (*T, error) {
-	idx := t.IndexCalculator(date)
-	if idx < 0 || idx > t.maxIndex {
-		return nil, nil
-	}
-	d := t.data[idx]
+	d := t.getItem(t.IndexCalculator(date))
 	if d != nil {
 		return t.get(d)
 	}
 	return nil, nil
 }
 
+func (t *TimeSeriesData[T]) getItem(idx int) *LruItem[T] {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.item(idx)
+}
+
 func (t *TimeSeriesData[T]) get(item *LruItem[T]) (*T, error) {
+	t.lock.Lock()
+	for item.Data == nil && item.loading != nil {
+		// the same item is being loaded by another goroutine
+		loading := item.loading
+		t.lock.Unlock()
+		<-loading
+		t.lock.Lock()
+	}
 	if item.Data != nil {
-		t.lruManager.MoveToFront(item)
-		return item.Data, nil
+		t.stats.Hits++
+		t.policy.Touch(item)
+		data := item.Data
+		t.lock.Unlock()
+		return data, nil
+	}
+	t.stats.Misses++
+	item.loading = make(chan struct{})
+	t.lock.Unlock()
+
+	data, err := t.load(item.Date)
+	var size int
+	if err == nil {
+		size = t.estimateSize(data)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	close(item.loading)
+	item.loading = nil
+	if err != nil {
+		return nil, err
+	}
+	if t.item(item.Key) != item {
+		// the item was removed or replaced during the load
+		return data, nil
 	}
-	err := t.cleanup()
+	err = t.cleanup(size)
 	if err != nil {
 		return nil, err
 	}
-	files, err := t.source.GetFiles(item.Date, t.dataFolderPath)
+	t.attach(item, data, size)
+
+	return data, nil
+}
+
+func (t *TimeSeriesData[T]) load(date int) (*T, error) {
+	files, err := t.source.GetFiles(date, t.dataFolderPath)
 	if err != nil {
 		return nil, err
 	}
-	var data *T
-	data, err = t.source.Load(files)
+	return t.loadFiles(files)
+}
+
+// loadFiles loads an item and updates load counters, it should be called without lock
+func (t *TimeSeriesData[T]) loadFiles(files []FileWithDate) (*T, error) {
+	start := time.Now()
+	data, err := t.source.Load(files)
 	if err != nil {
 		return nil, err
 	}
-	item.Data = data
-	t.lruManager.Attach(item)
+	elapsed := time.Since(start)
+	size := filesSize(files)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.stats.Loads++
+	t.stats.LoadTime += elapsed
+	t.stats.BytesRead += size
+	return data, nil
+}
 
-	return item.Data, nil
+// saveItem saves an item and updates write counters
+func (t *TimeSeriesData[T]) saveItem(item *LruItem[T], source DatedSource[T], dataFolderPath string) error {
+	err := t.invalidateManifest(dataFolderPath)
+	if err != nil {
+		return err
+	}
+	err = source.Save(item.Date, item.Data, dataFolderPath)
+	if err != nil {
+		return err
+	}
+	err = t.updateManifest(item.Key, item.Date, dataFolderPath)
+	if err != nil {
+		return err
+	}
+	t.stats.WriteBacks++
+	files, err := source.GetFiles(item.Date, dataFolderPath)
+	if err == nil {
+		t.stats.BytesWritten += filesSize(files)
+	}
+	return nil
+}
+
+func (t *TimeSeriesData[T]) estimateSize(data *T) int {
+	if t.estimator == nil || t.maxActiveBytes <= 0 {
+		return 0
+	}
+	return t.estimator.EstimateSize(data)
 }
 
-func (t *TimeSeriesData[T]) cleanup() error {
-	for t.lruManager.activeItems >= t.maxActiveItems {
-		err := t.removeByLru()
+// cleanup removes least recently used items, so an item of given estimated size can be added
+func (t *TimeSeriesData[T]) cleanup(size int) error {
+	for t.activeItems >= t.maxActiveItems || t.exceedsMaxActiveBytes(size) {
+		err := t.evict()
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	return t.saveManifest()
 }
 
-func (t *TimeSeriesData[T]) removeByLru() error {
-	tail := t.lruManager.GetTail()
-	if t.modified[tail.Key] {
-		err := t.source.Save(tail.Date, tail.Data, t.dataFolderPath)
+func (t *TimeSeriesData[T]) exceedsMaxActiveBytes(size int) bool {
+	return t.maxActiveBytes > 0 && t.activeItems > 0 && t.activeBytes+size > t.maxActiveBytes
+}
+
+// attach adds loaded data to the eviction policy
+func (t *TimeSeriesData[T]) attach(item *LruItem[T], data *T, size int) *LruItem[T] {
+	item.Data = data
+	item.size = size
+	t.policy.Attach(item)
+	t.activeItems++
+	t.activeBytes += size
+	return item
+}
+
+// resize updates estimated size of a loaded item
+func (t *TimeSeriesData[T]) resize(item *LruItem[T], size int) {
+	t.activeBytes += size - item.size
+	item.size = size
+}
+
+// evict saves if modified and removes from memory an item chosen by the eviction policy
+func (t *TimeSeriesData[T]) evict() error {
+	victim := t.policy.Victim()
+	if victim == nil {
+		return errors.New("eviction policy returned no item")
+	}
+	if _, ok := t.modified[victim.Key]; ok {
+		err := t.saveItem(victim, t.source, t.dataFolderPath)
 		if err != nil {
 			return err
 		}
-		delete(t.modified, tail.Key)
+		delete(t.modified, victim.Key)
+		delete(t.pending, victim.Key)
 	}
-	tail.Data = nil
-	t.lruManager.Detach(tail)
+	t.detach(victim)
+	t.stats.Evictions++
 	return nil
 }
 
+// detach removes loaded data from memory and the eviction policy
+func (t *TimeSeriesData[T]) detach(item *LruItem[T]) {
+	item.Data = nil
+	t.policy.Detach(item)
+	t.activeItems--
+	t.activeBytes -= item.size
+}
+
 func (t *TimeSeriesData[T]) saveIndex(index int, source DatedSource[T], dataFolderPath string) error {
-	d := t.data[index]
+	d := t.item(index)
 	if d != nil && d.Data != nil {
-		err := source.Save(d.Date, d.Data, dataFolderPath)
+		err := t.saveItem(d, source, dataFolderPath)
 		if err != nil {
 			return err
 		}
-		delete(t.modified, index)
 	}
+	delete(t.modified, index)
 	return nil
 }
 
 func (t *TimeSeriesData[T]) SaveAll(source DatedSource[T], dataFolderPath string) error {
-	for i := 0; i <= t.maxIndex; i++ {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for i := t.minIndex; i <= t.maxIndex; i++ {
 		err := t.saveIndex(i, source, dataFolderPath)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	return t.saveManifest()
+}
+
+// Rewrite loads and saves all items, so their files are written in the latest schema version
+func (t *TimeSeriesData[T]) Rewrite() error {
+	t.lock.Lock()
+	keys := slices.Clone(t.keys)
+	t.lock.Unlock()
+	for _, idx := range keys {
+		item := t.getItem(idx)
+		if item == nil {
+			continue
+		}
+		t.lock.Lock()
+		// the item is saved by evict when it is unloaded before saveIndex
+		t.markAsModified(idx)
+		t.lock.Unlock()
+		_, err := t.get(item)
+		if err != nil {
+			return err
+		}
+		t.lock.Lock()
+		err = t.saveIndex(idx, t.source, t.dataFolderPath)
+		t.lock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return t.Save()
 }
 
 func (t *TimeSeriesData[T]) Save() error {
-	var toDelete []int
-	for idx := range t.modified {
-		toDelete = append(toDelete, idx)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.flush(0)
+}
+
+// Flush saves items modified more than maxDirtyAge ago
+func (t *TimeSeriesData[T]) Flush(maxDirtyAge time.Duration) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.flush(maxDirtyAge)
+}
+
+func (t *TimeSeriesData[T]) flush(maxDirtyAge time.Duration) error {
+	// an idle flusher should not touch the journal
+	if len(t.modified) == 0 && len(t.pending) == 0 && !t.manifestChanged && (t.journal == nil || t.journal.size == 0) {
+		return nil
+	}
+	now := time.Now()
+	var indexes []int
+	for idx, since := range t.modified {
+		if now.Sub(since) >= maxDirtyAge {
+			indexes = append(indexes, idx)
+		}
+	}
+	// journal should contain the latest state of items that stay modified
+	if len(indexes) < len(t.modified) {
+		err := t.commit()
+		if err != nil {
+			return err
+		}
 	}
-	for _, idx := range toDelete {
+	sort.Ints(indexes)
+	var firstErr error
+	for _, idx := range indexes {
 		err := t.saveIndex(idx, t.source, t.dataFolderPath)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	err := t.saveManifest()
+	if err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if firstErr != nil || len(t.modified) > 0 {
+		return firstErr
+	}
+	// checkpoint: all journal records are saved
+	clear(t.pending)
+	if t.journal != nil {
+		return t.journal.truncate()
+	}
+	return nil
+}
+
+// startFlusher starts the background flusher when WithFlusher option is set
+func (t *TimeSeriesData[T]) startFlusher() {
+	if t.flushInterval <= 0 {
+		return
+	}
+	t.stopFlusher = make(chan struct{})
+	t.flusherDone = make(chan struct{})
+	go func() {
+		defer close(t.flusherDone)
+		ticker := time.NewTicker(t.flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.stopFlusher:
+				return
+			case <-ticker.C:
+				if t.flushLocker != nil {
+					t.flushLocker.Lock()
+				}
+				err := t.Flush(t.maxDirtyAge)
+				if t.flushLocker != nil {
+					t.flushLocker.Unlock()
+				}
+				if err != nil && t.onFlushError != nil {
+					t.onFlushError(err)
+				}
+			}
+		}
+	}()
+}
+
+// Commit writes all items modified since the previous commit to the journal
+func (t *TimeSeriesData[T]) Commit() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.commit()
+}
+
+func (t *TimeSeriesData[T]) commit() error {
+	if t.journal == nil {
+		clear(t.pending)
+		return nil
+	}
+	var indexes []int
+	for idx := range t.pending {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+	var records []journalRecord
+	for _, idx := range indexes {
+		d := t.item(idx)
+		if d == nil || d.Data == nil {
+			continue
+		}
+		data, err := t.journalCodec.Marshal(d.Data)
 		if err != nil {
 			return err
 		}
+		records = append(records, journalRecord{index: idx, date: d.Date, data: data})
+	}
+	if len(records) == 0 {
+		clear(t.pending)
+		return nil
+	}
+	err := t.journal.append(records)
+	if err != nil {
+		return err
+	}
+	clear(t.pending)
+	return nil
+}
+
+// Close stops the flusher and closes the journal, modified items are not saved
+func (t *TimeSeriesData[T]) Close() error {
+	if t.stopFlusher != nil {
+		close(t.stopFlusher)
+		<-t.flusherDone
+		t.stopFlusher = nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.journal != nil {
+		err := t.journal.close()
+		t.journal = nil
+		return err
 	}
 	return nil
 }
 
+// TimeSeriesDataIterator iterates over items in ascending or descending index order
 type TimeSeriesDataIterator[T any] struct {
 	data        *TimeSeriesData[T]
 	current     int
+	from        int
 	to          int
+	step        int
 	currentData *T
+	// number of items to return, negative value means no limit
+	remaining int
+	err       error
+}
+
+func (i *TimeSeriesDataIterator[T]) inRange() bool {
+	return i.current >= i.from && i.current <= i.to
 }
 
 func (i *TimeSeriesDataIterator[T]) HasNext() bool {
-	return i.current <= i.to
+	return i.remaining != 0 && i.inRange()
 }
 
 func (i *TimeSeriesDataIterator[T]) seekToNext() error {
-	i.current++
-	for i.current <= i.to {
-		d := i.data.data[i.current]
+	i.current += i.step
+	for i.inRange() {
+		d := i.data.getItem(i.current)
 		if d != nil {
 			var err error
 			i.currentData, err = i.data.get(d)
+			if err != nil {
+				i.err = err
+			}
 			return err
 		}
-		i.current++
+		i.current += i.step
 	}
 	return nil
 }
 
+// Next returns the current item and loads the next one, returned error is the next item load error
 func (i *TimeSeriesDataIterator[T]) Next() (int, *T, error) {
 	next := i.current
 	data := i.currentData
+	if i.remaining > 0 {
+		i.remaining--
+		// the next item is not needed
+		if i.remaining == 0 {
+			return next, data, nil
+		}
+	}
 	return next, data, i.seekToNext()
 }
 
-func (t *TimeSeriesData[T]) Iterator(from, to int) (*TimeSeriesDataIterator[T], error) {
-	idx1 := t.IndexCalculator(from)
-	if idx1 < 0 {
-		idx1 = 0
+// Limit limits the number of items to return
+func (i *TimeSeriesDataIterator[T]) Limit(limit int) *TimeSeriesDataIterator[T] {
+	i.remaining = limit
+	return i
+}
+
+// All returns range-over-func adapter, iteration stops at the first load error which is returned by Err
+func (i *TimeSeriesDataIterator[T]) All() iter.Seq2[int, *T] {
+	return func(yield func(int, *T) bool) {
+		if i.err != nil {
+			return
+		}
+		for i.HasNext() {
+			idx, data, err := i.Next()
+			if !yield(idx, data) || err != nil {
+				return
+			}
+		}
 	}
+}
+
+// Err returns the first load error
+func (i *TimeSeriesDataIterator[T]) Err() error {
+	return i.err
+}
+
+func (t *TimeSeriesData[T]) newIterator(from, to, step int) (*TimeSeriesDataIterator[T], error) {
+	idx1 := t.IndexCalculator(from)
 	idx2 := t.IndexCalculator(to)
+	t.lock.Lock()
+	if idx1 < t.minIndex {
+		idx1 = t.minIndex
+	}
 	if idx2 > t.maxIndex {
 		idx2 = t.maxIndex
 	}
-	i := TimeSeriesDataIterator[T]{t, idx1 - 1, idx2, nil}
+	t.lock.Unlock()
+	i := TimeSeriesDataIterator[T]{data: t, from: idx1, to: idx2, step: step, remaining: -1}
+	if step > 0 {
+		i.current = idx1 - step
+	} else {
+		i.current = idx2 - step
+	}
 	err := i.seekToNext()
 	return &i, err
 }
 
+// Iterator iterates over items for dates from..to in ascending order
+func (t *TimeSeriesData[T]) Iterator(from, to int) (*TimeSeriesDataIterator[T], error) {
+	return t.newIterator(from, to, 1)
+}
+
+// ReverseIterator iterates over items for dates from..to in descending order
+func (t *TimeSeriesData[T]) ReverseIterator(from, to int) (*TimeSeriesDataIterator[T], error) {
+	return t.newIterator(from, to, -1)
+}
+
 func (t *TimeSeriesData[T]) MarkAsModified(idx int) {
-	t.modified[idx] = true
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.markAsModified(idx)
+	t.pending[idx] = true
+	// modified item size may be changed
+	item := t.item(idx)
+	if item != nil && item.Data != nil {
+		t.resize(item, t.estimateSize(item.Data))
+	}
+}
+
+func (t *TimeSeriesData[T]) Stats() TimeSeriesDataStats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	stats := t.stats
+	stats.ActiveItems = t.activeItems
+	stats.ActiveBytes = t.activeBytes
+	return stats
+}
+
+func (t *TimeSeriesData[T]) markAsModified(idx int) {
+	if _, ok := t.modified[idx]; !ok {
+		t.modified[idx] = time.Now()
+	}
+}
+
+// Remove removes an item for the date from memory and deletes its files
+func (t *TimeSeriesData[T]) Remove(date int) error {
+	return t.RemoveRange(date, date)
+}
+
+// RemoveRange removes items for dates from..to from memory and deletes their files
+func (t *TimeSeriesData[T]) RemoveRange(from, to int) error {
+	idx1 := t.IndexCalculator(from)
+	idx2 := t.IndexCalculator(to)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var records []journalRecord
+	for idx := max(idx1, t.minIndex); idx <= min(idx2, t.maxIndex); idx++ {
+		item := t.item(idx)
+		if item != nil {
+			records = append(records, journalRecord{index: idx, date: item.Date, removed: true})
+		}
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	// journal records of removed items should not be replayed after a crash
+	if t.journal != nil {
+		err := t.journal.append(records)
+		if err != nil {
+			return err
+		}
+	}
+	for _, r := range records {
+		err := t.remove(r.index, r.date)
+		if err != nil {
+			return err
+		}
+	}
+	return t.saveManifest()
+}
+
+func (t *TimeSeriesData[T]) remove(k, date int) error {
+	err := t.invalidateManifest(t.dataFolderPath)
+	if err != nil {
+		return err
+	}
+	err = t.source.Delete(date, t.dataFolderPath)
+	if err != nil {
+		return err
+	}
+	if t.manifest != nil {
+		delete(t.manifest, k)
+	}
+	item := t.item(k)
+	if item == nil {
+		return nil
+	}
+	if item.Data != nil {
+		t.detach(item)
+	}
+	delete(t.modified, k)
+	delete(t.pending, k)
+	t.data[k-t.base] = nil
+	if pos, found := slices.BinarySearch(t.keys, k); found {
+		t.keys = slices.Delete(t.keys, pos, pos+1)
+	}
+	// keeps minIndex and maxIndex pointing to existing items
+	for t.minIndex <= t.maxIndex && t.data[t.minIndex-t.base] == nil {
+		t.minIndex++
+	}
+	for t.maxIndex >= t.minIndex && t.data[t.maxIndex-t.base] == nil {
+		t.maxIndex--
+	}
+	return nil
+}
+
+// Bounds returns minimum and maximum item indexes, maximum is less than minimum when there are no items
+func (t *TimeSeriesData[T]) Bounds() (int, int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.minIndex, t.maxIndex
 }
 
 func (t *TimeSeriesData[T]) GetDate(key int) int {
-	return t.data[key].Date
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	d := t.item(key)
+	if d == nil {
+		return 0
+	}
+	return d.Date
 }
\ No newline at end of file
@@ -1,6 +1,15 @@
 package core
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
 
 type testData struct{}
 
@@ -26,36 +35,44 @@ func (t testDatedSource) Save(date int, data *testData, dataFolderPath string) e
 	panic("implement me")
 }
 
+func (t testDatedSource) Delete(date int, dataFolderPath string) error {
+	return nil
+}
+
+func lru(data *TimeSeriesData[testData]) *LruManager[testData] {
+	return data.policy.(*LruManager[testData])
+}
+
 func TestLruList(t *testing.T) {
 	data := NewTimeSeriesData[testData]("", testDatedSource{}, 500,
 		func(date int) int { return date }, func(date int) int { return date }, 500)
 	for i := 0; i < 3; i++ {
 		_ = data.Add(i, i, &testData{})
 	}
-	if data.lruManager.head.Key != 2 {
+	if lru(data).head.Key != 2 {
 		t.Fatal("head should be 2")
 	}
-	if data.lruManager.head.prev != nil {
+	if lru(data).head.prev != nil {
 		t.Fatal("head prev should be nil")
 	}
-	item := data.lruManager.head.next
+	item := lru(data).head.next
 	if item.Key != 1 {
 		t.Fatal("head next key should be 1")
 	}
-	if data.lruManager.tail.Key != 0 {
+	if lru(data).tail.Key != 0 {
 		t.Fatal("tail should be 0")
 	}
-	if data.lruManager.tail.prev != item {
-		t.Fatal("data.lruManager.tail.prev != data.lruManager.head.next")
+	if lru(data).tail.prev != item {
+		t.Fatal("lru(data).tail.prev != lru(data).head.next")
 	}
-	if data.lruManager.tail.next != nil {
+	if lru(data).tail.next != nil {
 		t.Fatal("tail next should be nil")
 	}
-	if item.prev != data.lruManager.head {
-		t.Fatal("item.prev != data.lruManager.head")
+	if item.prev != lru(data).head {
+		t.Fatal("item.prev != lru(data).head")
 	}
-	if item.next != data.lruManager.tail {
-		t.Fatal("item.next != data.lruManager.tail")
+	if item.next != lru(data).tail {
+		t.Fatal("item.next != lru(data).tail")
 	}
 }
 
@@ -68,25 +85,25 @@ func TestLruExpireAndMoveToFront(t *testing.T) {
 			t.Fatal(err)
 		}
 	}
-	if data.lruManager.activeItems != 500 {
-		t.Fatal("data.lruManager.activeItems should be 500")
+	if data.activeItems != 500 {
+		t.Fatal("data.activeItems should be 500")
 	}
-	if data.lruManager.head.Key != 999 {
+	if lru(data).head.Key != 999 {
 		t.Fatal("head should be 999")
 	}
-	if data.lruManager.head.prev != nil {
+	if lru(data).head.prev != nil {
 		t.Fatal("head prev should be nil")
 	}
-	if data.lruManager.head.next.Key != 998 {
+	if lru(data).head.next.Key != 998 {
 		t.Fatal("head next key should be 998")
 	}
-	if data.lruManager.tail.Key != 500 {
+	if lru(data).tail.Key != 500 {
 		t.Fatal("tail should be 500")
 	}
-	if data.lruManager.tail.prev.Key != 501 {
+	if lru(data).tail.prev.Key != 501 {
 		t.Fatal("tail prev key should be 501")
 	}
-	if data.lruManager.tail.next != nil {
+	if lru(data).tail.next != nil {
 		t.Fatal("tail next should be nil")
 	}
 
@@ -97,13 +114,13 @@ func TestLruExpireAndMoveToFront(t *testing.T) {
 	if key != 501 {
 		t.Fatal("key should be 501")
 	}
-	if data.lruManager.head.Key != 501 {
+	if lru(data).head.Key != 501 {
 		t.Fatal("head should be 501")
 	}
-	if data.lruManager.head.prev != nil {
+	if lru(data).head.prev != nil {
 		t.Fatal("head prev should be nil")
 	}
-	if data.lruManager.head.next.Key != 999 {
+	if lru(data).head.next.Key != 999 {
 		t.Fatal("head next key should be 999")
 	}
 }
@@ -155,3 +172,576 @@ func TestIterator(t *testing.T) {
 		t.Fatal("HasNext7")
 	}
 }
+
+type countingDatedSource struct {
+	lock  sync.Mutex
+	loads map[int]int
+}
+
+func (c *countingDatedSource) GetFileDate(fileName string, folderName string) (int, error) {
+	return strconv.Atoi(fileName)
+}
+
+func (c *countingDatedSource) Load(files []FileWithDate) (*testData, error) {
+	time.Sleep(time.Millisecond)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.loads[files[0].Date]++
+	return &testData{}, nil
+}
+
+func (c *countingDatedSource) GetFiles(date int, dataFolderPath string) ([]FileWithDate, error) {
+	return []FileWithDate{{FileName: strconv.Itoa(date), Date: date}}, nil
+}
+
+func (c *countingDatedSource) Save(date int, data *testData, dataFolderPath string) error {
+	return nil
+}
+
+func (c *countingDatedSource) Delete(date int, dataFolderPath string) error {
+	return nil
+}
+
+func newCountingTimeSeriesData(items, maxActiveItems int) (*TimeSeriesData[testData], *countingDatedSource) {
+	source := &countingDatedSource{loads: make(map[int]int)}
+	data := NewTimeSeriesData[testData]("", source, items,
+		func(date int) int { return date }, func(date int) int { return date }, maxActiveItems)
+	for i := 0; i < items; i++ {
+		data.set(i, NewLruItem[testData](i, i))
+	}
+	return data, source
+}
+
+func TestConcurrentLoadOfTheSameItem(t *testing.T) {
+	data, source := newCountingTimeSeriesData(10, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := data.GetExact(5)
+			if err != nil || v == nil {
+				t.Error("GetExact failed")
+			}
+		}()
+	}
+	wg.Wait()
+	if source.loads[5] != 1 {
+		t.Fatalf("item should be loaded once, loaded %v times", source.loads[5])
+	}
+	if data.activeItems != 1 {
+		t.Fatal("data.activeItems should be 1")
+	}
+}
+
+type blockingDatedSource struct {
+	countingDatedSource
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingDatedSource) Load(files []FileWithDate) (*testData, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return b.countingDatedSource.Load(files)
+}
+
+func TestRemoveDuringLoad(t *testing.T) {
+	source := &blockingDatedSource{countingDatedSource: countingDatedSource{loads: make(map[int]int)},
+		started: make(chan struct{}), release: make(chan struct{})}
+	data := NewTimeSeriesData[testData]("", source, 10,
+		func(date int) int { return date }, func(date int) int { return date }, 10)
+	for i := 0; i < 10; i++ {
+		data.set(i, NewLruItem[testData](i, i))
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		v, err := data.GetExact(5)
+		if err != nil || v == nil {
+			t.Error("GetExact failed")
+		}
+	}()
+	<-source.started
+	err := data.Remove(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(source.release)
+	<-done
+	// the removed item should not be added to the eviction policy
+	if data.activeItems != 0 || data.policy.Victim() != nil {
+		t.Fatal("removed item should not be attached")
+	}
+}
+
+func TestConcurrentReaders(t *testing.T) {
+	data, _ := newCountingTimeSeriesData(100, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				idx := (start*7 + j) % 100
+				v, err := data.GetExact(idx)
+				if err != nil || v == nil {
+					t.Error("GetExact failed")
+					return
+				}
+			}
+			iter, err := data.Iterator(0, 99)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for iter.HasNext() {
+				_, v, err := iter.Next()
+				if err != nil || v == nil {
+					t.Error("Next failed")
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if data.activeItems > 10 {
+		t.Fatalf("too many active items: %v", data.activeItems)
+	}
+}
+
+func TestGrowAndRebase(t *testing.T) {
+	data := NewTimeSeriesData[testData]("", testDatedSource{}, 2,
+		func(date int) int { return date }, func(date int) int { return date }, 500)
+	for _, idx := range []int{1, 10, -5, 100, -300} {
+		err := data.Add(idx, idx, &testData{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, idx := range []int{1, 10, -5, 100, -300} {
+		v, err := data.GetExact(idx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v == nil {
+			t.Fatalf("item %v should exist", idx)
+		}
+		if data.GetDate(idx) != idx {
+			t.Fatalf("wrong date for item %v", idx)
+		}
+	}
+	v, err := data.GetExact(-301)
+	if err != nil || v != nil {
+		t.Fatal("item -301 should not exist")
+	}
+	iter, err := data.Iterator(-1000, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indexes []int
+	for iter.HasNext() {
+		idx, _, err := iter.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexes = append(indexes, idx)
+	}
+	if !reflect.DeepEqual(indexes, []int{-300, -5, 1, 10, 100}) {
+		t.Fatalf("unexpected indexes %v", indexes)
+	}
+	err = data.Add(-300+maxTimeSeriesDataSize, 0, &testData{})
+	if err == nil {
+		t.Fatal("index out of range should be rejected")
+	}
+	if data.activeItems != 5 {
+		t.Fatal("rejected item should not be added")
+	}
+}
+
+type sizedDatedSource struct {
+	countingDatedSource
+}
+
+func (s *sizedDatedSource) EstimateSize(data *testData) int {
+	return 100
+}
+
+func TestMaxActiveBytes(t *testing.T) {
+	source := &sizedDatedSource{countingDatedSource{loads: make(map[int]int)}}
+	data := NewTimeSeriesData[testData]("", source, 10,
+		func(date int) int { return date }, func(date int) int { return date }, 100,
+		WithMaxActiveBytes[testData](350))
+	for i := 0; i < 10; i++ {
+		err := data.set(i, NewLruItem[testData](i, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		_, err := data.GetExact(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data.activeItems != 3 {
+		t.Fatalf("active items should be 3, got %v", data.activeItems)
+	}
+	if data.activeBytes != 300 {
+		t.Fatalf("active bytes should be 300, got %v", data.activeBytes)
+	}
+	if lru(data).tail.Key != 7 {
+		t.Fatal("tail should be 7")
+	}
+	// count limit is still used
+	data.maxActiveItems = 2
+	_, err := data.GetExact(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.activeItems != 2 {
+		t.Fatalf("active items should be 2, got %v", data.activeItems)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	folder := t.TempDir()
+	data := NewTimeSeriesData[testValue](folder, fileDatedSource{}, 10,
+		func(date int) int { return date }, func(date int) int { return date }, 10)
+	for i := 1; i <= 2; i++ {
+		err := data.Add(i, i, &testValue{i})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	data.MarkAsModified(1)
+	time.Sleep(20 * time.Millisecond)
+	data.MarkAsModified(2)
+	err := data.Flush(10 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(folder + "/1.bin"); err != nil {
+		t.Fatal("old modified item should be saved")
+	}
+	if _, err = os.Stat(folder + "/2.bin"); !os.IsNotExist(err) {
+		t.Fatal("recently modified item should not be saved")
+	}
+	if len(data.modified) != 1 {
+		t.Fatal("second item should stay modified")
+	}
+}
+
+type failingDatedSource struct {
+	fileDatedSource
+}
+
+func (s failingDatedSource) Save(date int, data *testValue, dataFolderPath string) error {
+	return errors.New("save error")
+}
+
+func TestFlusher(t *testing.T) {
+	folder := t.TempDir()
+	data := NewTimeSeriesData[testValue](folder, fileDatedSource{}, 10,
+		func(date int) int { return date }, func(date int) int { return date }, 10,
+		WithFlusher[testValue](time.Millisecond, 0, &sync.Mutex{}, func(err error) { t.Error(err) }))
+	err := data.Add(1, 1, &testValue{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.MarkAsModified(1)
+	for i := 0; i < 1000; i++ {
+		if _, err = os.Stat(folder + "/1.bin"); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	_ = data.Close()
+	if err != nil {
+		t.Fatal("modified item should be saved by the flusher")
+	}
+
+	errs := make(chan error, 100)
+	data = NewTimeSeriesData[testValue](folder, failingDatedSource{}, 10,
+		func(date int) int { return date }, func(date int) int { return date }, 10,
+		WithFlusher[testValue](time.Millisecond, 0, nil, func(err error) { errs <- err }))
+	err = data.Add(2, 2, &testValue{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.MarkAsModified(2)
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Fatal("save error should be reported")
+	}
+	_ = data.Close()
+	if _, ok := data.modified[2]; !ok {
+		t.Fatal("item that was not saved should stay modified")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	folder := t.TempDir()
+	data := NewTimeSeriesData[testValue](folder, fileDatedSource{}, 10,
+		func(date int) int { return date }, func(date int) int { return date }, 10)
+	for i := 1; i <= 5; i++ {
+		err := data.Add(i, i, &testValue{i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data.MarkAsModified(i)
+	}
+	err := data.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.MarkAsModified(1)
+	err = data.Remove(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = data.RemoveRange(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = data.Remove(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	minIndex, maxIndex := data.Bounds()
+	if minIndex != 3 || maxIndex != 4 {
+		t.Fatalf("wrong bounds %v %v", minIndex, maxIndex)
+	}
+	if data.activeItems != 2 || len(data.modified) != 0 {
+		t.Fatal("removed items should not be active or modified")
+	}
+	for i := 1; i <= 5; i++ {
+		_, err = os.Stat(fileDatedSource{}.getFileName(i, folder))
+		if (i == 3 || i == 4) != (err == nil) {
+			t.Fatalf("unexpected file state for item %v", i)
+		}
+	}
+	err = data.RemoveRange(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := data.GetExact(3)
+	if err != nil || v != nil {
+		t.Fatal("item 3 should be removed")
+	}
+	err = data.Add(7, 7, &testValue{7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	minIndex, maxIndex = data.Bounds()
+	if minIndex != 7 || maxIndex != 7 {
+		t.Fatalf("wrong bounds %v %v", minIndex, maxIndex)
+	}
+}
+
+func collectIndexes(t *testing.T, iter *TimeSeriesDataIterator[testData], err error) []int {
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indexes []int
+	for idx := range iter.All() {
+		indexes = append(indexes, idx)
+	}
+	if iter.Err() != nil {
+		t.Fatal(iter.Err())
+	}
+	return indexes
+}
+
+func TestReverseIteratorAndLimit(t *testing.T) {
+	data, source := newCountingTimeSeriesData(10, 10)
+	iter, err := data.ReverseIterator(2, 100)
+	indexes := collectIndexes(t, iter, err)
+	if !reflect.DeepEqual(indexes, []int{9, 8, 7, 6, 5, 4, 3, 2}) {
+		t.Fatalf("unexpected indexes %v", indexes)
+	}
+	iter, err = data.ReverseIterator(-5, 7)
+	indexes = collectIndexes(t, iter.Limit(3), err)
+	if !reflect.DeepEqual(indexes, []int{7, 6, 5}) {
+		t.Fatalf("unexpected indexes %v", indexes)
+	}
+	if source.loads[4] != 1 {
+		t.Fatal("item after the limit should not be loaded")
+	}
+	iter, err = data.Iterator(0, 100)
+	indexes = collectIndexes(t, iter.Limit(2), err)
+	if !reflect.DeepEqual(indexes, []int{0, 1}) {
+		t.Fatalf("unexpected indexes %v", indexes)
+	}
+	// early termination
+	iter, err = data.Iterator(0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx := range iter.All() {
+		if idx == 3 {
+			break
+		}
+	}
+	if !iter.HasNext() || iter.Err() != nil {
+		t.Fatal("iterator should stay usable after break")
+	}
+}
+
+func TestIteratorErrorPropagation(t *testing.T) {
+	folder := t.TempDir()
+	data := NewTimeSeriesData[testValue](folder, fileDatedSource{}, 10,
+		func(date int) int { return date }, func(date int) int { return date }, 10)
+	for i := 1; i <= 5; i++ {
+		err := data.set(i, NewLruItem[testValue](i, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// item 4 has no file
+		if i != 4 {
+			err = SaveBinary(fileDatedSource{}.getFileName(i, folder), nil, &testValue{i})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	iter, err := data.Iterator(1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []int
+	for _, v := range iter.All() {
+		values = append(values, v.Value)
+	}
+	if !reflect.DeepEqual(values, []int{1, 2, 3}) {
+		t.Fatalf("unexpected values %v", values)
+	}
+	if iter.Err() == nil {
+		t.Fatal("load error should be returned")
+	}
+}
+
+func TestLookups(t *testing.T) {
+	data := NewTimeSeriesData[testData]("", testDatedSource{}, 10,
+		func(date int) int { return date }, func(date int) int { return date }, 500)
+	for _, idx := range []int{10, 20, 23, 40} {
+		err := data.Add(idx, idx, &testData{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(name string, lookup func(int) (int, *testData, error), date, expected int) {
+		idx, v, err := lookup(date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected < 0 {
+			if v != nil || idx != date {
+				t.Fatalf("%v(%v) should not find an item, got %v", name, date, idx)
+			}
+		} else if v == nil || idx != expected {
+			t.Fatalf("%v(%v) returned %v, expected %v", name, date, idx, expected)
+		}
+	}
+	check("Get", data.Get, 15, 10)
+	check("Floor", data.Floor, 5, -1)
+	check("Floor", data.Floor, 20, 20)
+	check("Floor", data.Floor, 39, 23)
+	check("Floor", data.Floor, 100, 40)
+	check("Ceiling", data.Ceiling, 5, 10)
+	check("Ceiling", data.Ceiling, 21, 23)
+	check("Ceiling", data.Ceiling, 41, -1)
+	check("Nearest", data.Nearest, 0, 10)
+	check("Nearest", data.Nearest, 15, 10)
+	check("Nearest", data.Nearest, 22, 23)
+	check("Nearest", data.Nearest, 32, 40)
+	check("Nearest", data.Nearest, 100, 40)
+	err := data.Remove(23)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("Floor", data.Floor, 39, 20)
+	check("Ceiling", data.Ceiling, 21, 40)
+	err = data.RemoveRange(0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("Nearest", data.Nearest, 15, -1)
+}
+
+func TestParallelLoad(t *testing.T) {
+	folder := t.TempDir()
+	source := fileDatedSource{}
+	for i := 1; i <= 50; i++ {
+		err := source.Save(i, &testValue{i}, folder)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var progress []int
+	data, err := LoadTimeSeriesData[testValue](folder, source, 10,
+		func(date int) int { return date }, func(date int) int { return date }, 10,
+		WithLoadWorkers[testValue](4), WithLoadProgress[testValue](func(loaded, total int) {
+			if total != 50 {
+				t.Errorf("wrong total %v", total)
+			}
+			progress = append(progress, loaded)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) != 50 || progress[49] != 50 {
+		t.Fatalf("unexpected progress %v", progress)
+	}
+	// items are added in index order, so the latest ones stay loaded
+	item := data.policy.(*LruManager[testValue]).head
+	for i := 50; i > 40; i-- {
+		if item == nil || item.Key != i || item.Data.Value != i {
+			t.Fatalf("unexpected lru item for %v", i)
+		}
+		item = item.next
+	}
+	checkTestValue(t, data, 1, 1)
+
+	err = os.WriteFile(source.getFileName(20, folder), []byte{1}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadTimeSeriesData[testValue](folder, source, 10,
+		func(date int) int { return date }, func(date int) int { return date }, 10, WithLoadWorkers[testValue](4))
+	if err == nil {
+		t.Fatal("load error should be returned")
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	folder := t.TempDir()
+	// files without the header
+	for date := 1; date <= 3; date++ {
+		err := os.WriteFile(folder+"/"+strconv.Itoa(date)+".bin", []byte{byte(date), 0, 0, 0}, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := InitTimeSeriesData[testValue](folder, fileDatedSource{}, 10,
+		func(date int) int { return date }, func(date int) int { return date }, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = data.Rewrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for date := 1; date <= 3; date++ {
+		fileData, err := os.ReadFile(folder + "/" + strconv.Itoa(date) + ".bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fileData) != fileHeaderSize+4 || !bytes.Equal(fileData[:len(fileMagic)], fileMagic) {
+			t.Fatalf("file for date %v is not rewritten", date)
+		}
+		checkTestValue(t, data, date, date)
+	}
+	checkManifest(t, folder, 1, 2, 3)
+}
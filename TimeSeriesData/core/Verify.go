@@ -0,0 +1,103 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// FileError describes a file that failed verification
+type FileError struct {
+	FileName string
+	Err      error
+}
+
+func (e FileError) Error() string {
+	var decodeErr *DecodeError
+	if errors.As(e.Err, &decodeErr) && decodeErr.FileName == e.FileName {
+		// the file name is already in the message
+		return e.Err.Error()
+	}
+	return e.FileName + ": " + e.Err.Error()
+}
+
+func (e FileError) Unwrap() error {
+	return e.Err
+}
+
+// Verify scans the data folder, loads every item without adding it to memory and compares file checksums
+// with the manifest. It returns errors for all bad files, the error result is returned when the folder cannot be read.
+func (t *TimeSeriesData[T]) Verify() ([]FileError, error) {
+	files, err := t.getFileList("")
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(files, func(a, b FileWithDate) int {
+		return strings.Compare(a.FileName, b.FileName)
+	})
+	var result []FileError
+	// nil when there is no valid manifest
+	var manifest map[string]ManifestEntry
+	entries, err := LoadManifest(t.dataFolderPath)
+	if err == nil {
+		manifest = make(map[string]ManifestEntry)
+		for _, e := range entries {
+			manifest[e.FileName] = e
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		result = append(result, FileError{FileName: getManifestFileName(t.dataFolderPath), Err: err})
+	}
+	groups := make(map[int][]FileWithDate)
+	for _, f := range files {
+		idx := t.IndexCalculator(f.Date)
+		groups[idx] = append(groups[idx], f)
+		result = t.verifyChecksum(f, manifest, result)
+	}
+	for _, name := range slices.Sorted(maps.Keys(manifest)) {
+		result = append(result, FileError{FileName: manifest[name].GetFilePath(t.dataFolderPath),
+			Err: errors.New("file from the manifest is missing")})
+	}
+	for _, idx := range slices.Sorted(maps.Keys(groups)) {
+		group := groups[idx]
+		_, err = t.source.Load(group)
+		if err != nil {
+			names := make([]string, len(group))
+			for i, f := range group {
+				names[i] = f.FileName
+			}
+			result = append(result, FileError{FileName: strings.Join(names, ", "), Err: err})
+		}
+	}
+	return result, nil
+}
+
+// verifyChecksum compares file checksum with the manifest entry and removes the entry from the manifest map
+func (t *TimeSeriesData[T]) verifyChecksum(file FileWithDate, manifest map[string]ManifestEntry,
+	result []FileError) []FileError {
+	if manifest == nil {
+		return result
+	}
+	name, err := filepath.Rel(t.dataFolderPath, file.FileName)
+	if err != nil {
+		return append(result, FileError{FileName: file.FileName, Err: err})
+	}
+	name = filepath.ToSlash(name)
+	expected, ok := manifest[name]
+	if !ok {
+		return append(result, FileError{FileName: file.FileName, Err: errors.New("file is not in the manifest")})
+	}
+	delete(manifest, name)
+	e, err := NewManifestEntry(t.dataFolderPath, file)
+	if err != nil {
+		return append(result, FileError{FileName: file.FileName, Err: err})
+	}
+	if e.Checksum != expected.Checksum {
+		return append(result, FileError{FileName: file.FileName,
+			Err: fmt.Errorf("checksum mismatch: %08x, expected %08x", e.Checksum, expected.Checksum)})
+	}
+	return result
+}
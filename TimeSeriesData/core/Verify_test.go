@@ -0,0 +1,57 @@
+package core
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	folder := t.TempDir()
+	source := fileDatedSource{}
+	for i := 1; i <= 3; i++ {
+		err := source.Save(i, &testValue{i}, folder)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := initManifestTestValues(t, folder)
+	errs, err := data.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	err = os.WriteFile(source.getFileName(1, folder), []byte{1, 0, 0, 0, 0}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Remove(source.getFileName(2, folder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = source.Save(4, &testValue{4}, folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, err = data.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"1.bin: checksum mismatch",
+		"4.bin: file is not in the manifest",
+		"2.bin: file from the manifest is missing",
+		"1.bin: offset 4: non zero buffer length",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	for i, e := range errs {
+		if !strings.Contains(e.Error(), expected[i]) {
+			t.Fatalf("unexpected error %v, expected %v", e, expected[i])
+		}
+	}
+}
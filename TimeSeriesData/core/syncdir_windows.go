@@ -0,0 +1,6 @@
+package core
+
+// directories cannot be flushed on windows, rename is durable after MoveFileEx
+func syncDir(_ string) error {
+	return nil
+}
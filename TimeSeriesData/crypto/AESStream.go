@@ -0,0 +1,175 @@
+package crypto
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+/*
+
+Encrypted stream structure (STREAM construction):
+|nonce prefix - 7 bytes|segment|segment|...|last segment|
+
+Every segment except the last one contains 64 KiB of data sealed with AES-GCM, the last segment contains
+0..64 KiB of data. Segment nonce structure:
+|nonce prefix - 7 bytes|segment number, big endian - 4 bytes|1 for the last segment, 0 for others - 1 byte|
+so segments cannot be reordered, removed or truncated without detection.
+
+*/
+
+const (
+	streamSegmentSize     = 64 * 1024
+	streamNoncePrefixSize = 7
+	streamNonceSize       = 12
+	streamTagSize         = 16
+)
+
+type aesStreamWriter struct {
+	aesgcm  cipher.AEAD
+	writer  io.Writer
+	nonce   []byte
+	counter uint32
+	buffer  []byte
+	closed  bool
+}
+
+type aesStreamReader struct {
+	aesgcm  cipher.AEAD
+	reader  *bufio.Reader
+	nonce   []byte
+	counter uint32
+	segment []byte
+	data    []byte
+	last    bool
+}
+
+// EncryptStream returns a writer that encrypts data in authenticated segments and writes them to writer.
+// Close writes the last segment, it does not close writer.
+func (a AESGcm) EncryptStream(writer io.Writer) (io.WriteCloser, error) {
+	nonce := make([]byte, streamNonceSize)
+	_, err := rand.Read(nonce[:streamNoncePrefixSize])
+	if err != nil {
+		return nil, err
+	}
+	_, err = writer.Write(nonce[:streamNoncePrefixSize])
+	if err != nil {
+		return nil, err
+	}
+	return &aesStreamWriter{aesgcm: a.aesgcm, writer: writer, nonce: nonce,
+		buffer: make([]byte, 0, streamSegmentSize+streamTagSize)}, nil
+}
+
+// DecryptStream returns a reader that decrypts data written by EncryptStream. Read returns an error
+// when a segment is modified or the stream is truncated.
+func (a AESGcm) DecryptStream(reader io.Reader) (io.Reader, error) {
+	nonce := make([]byte, streamNonceSize)
+	_, err := io.ReadFull(reader, nonce[:streamNoncePrefixSize])
+	if err != nil {
+		return nil, err
+	}
+	r, ok := reader.(*bufio.Reader)
+	if !ok {
+		r = bufio.NewReader(reader)
+	}
+	return &aesStreamReader{aesgcm: a.aesgcm, reader: r, nonce: nonce,
+		segment: make([]byte, streamSegmentSize+streamTagSize)}, nil
+}
+
+func setSegmentNonce(nonce []byte, counter uint32, last bool) {
+	binary.BigEndian.PutUint32(nonce[streamNoncePrefixSize:], counter)
+	if last {
+		nonce[streamNonceSize-1] = 1
+	} else {
+		nonce[streamNonceSize-1] = 0
+	}
+}
+
+func (w *aesStreamWriter) Write(data []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed stream")
+	}
+	n := 0
+	for len(data) > 0 {
+		// full segment is written when more data comes, so the last segment is written by Close
+		if len(w.buffer) == streamSegmentSize {
+			err := w.writeSegment(false)
+			if err != nil {
+				return n, err
+			}
+		}
+		l := min(streamSegmentSize-len(w.buffer), len(data))
+		w.buffer = append(w.buffer, data[:l]...)
+		data = data[l:]
+		n += l
+	}
+	return n, nil
+}
+
+func (w *aesStreamWriter) writeSegment(last bool) error {
+	if w.counter == math.MaxUint32 {
+		return errors.New("stream is too long")
+	}
+	setSegmentNonce(w.nonce, w.counter, last)
+	_, err := w.writer.Write(w.aesgcm.Seal(w.buffer[:0], w.nonce, w.buffer, nil))
+	w.buffer = w.buffer[:0]
+	w.counter++
+	return err
+}
+
+func (w *aesStreamWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.writeSegment(true)
+}
+
+func (r *aesStreamReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		if r.last {
+			return 0, io.EOF
+		}
+		err := r.readSegment()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (r *aesStreamReader) readSegment() error {
+	n, err := io.ReadFull(r.reader, r.segment)
+	last := false
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		last = true
+	} else if err != nil {
+		return err
+	} else {
+		// a full segment is the last one when there is no more data
+		_, err = r.reader.Peek(1)
+		if errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if r.counter == math.MaxUint32 {
+		return errors.New("stream is too long")
+	}
+	setSegmentNonce(r.nonce, r.counter, last)
+	data, err := r.aesgcm.Open(r.segment[:0], r.nonce, r.segment[:n], nil)
+	if err != nil {
+		return err
+	}
+	r.data = data
+	r.counter++
+	r.last = last
+	return nil
+}
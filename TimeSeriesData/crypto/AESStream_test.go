@@ -0,0 +1,90 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func encryptStream(t *testing.T, aes AESGcm, data []byte) []byte {
+	buffer := new(bytes.Buffer)
+	writer, err := aes.EncryptStream(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// writes data in parts to check segment boundaries
+	for len(data) > 0 {
+		l := min(len(data), 1000)
+		_, err = writer.Write(data[:l])
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = data[l:]
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func decryptStream(aes AESGcm, data []byte) ([]byte, error) {
+	reader, err := aes.DecryptStream(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+func TestAESGcmStream(t *testing.T) {
+	aes, err := NewAesGcm(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{0, 1, streamSegmentSize, streamSegmentSize + 1, 3*streamSegmentSize + 100} {
+		data := make([]byte, size)
+		_, _ = rand.Read(data)
+		encrypted := encryptStream(t, aes, data)
+		segments := max((size+streamSegmentSize-1)/streamSegmentSize, 1)
+		if len(encrypted) != streamNoncePrefixSize+size+segments*streamTagSize {
+			t.Fatalf("unexpected encrypted data size %v for %v bytes", len(encrypted), size)
+		}
+		decrypted, err := decryptStream(aes, encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, decrypted) {
+			t.Fatalf("different decrypted data for %v bytes", size)
+		}
+	}
+}
+
+func TestAESGcmStreamAuthentication(t *testing.T) {
+	aes, err := NewAesGcm(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 2*streamSegmentSize+100)
+	encrypted := encryptStream(t, aes, data)
+	segmentSize := streamSegmentSize + streamTagSize
+	// last segment is removed
+	_, err = decryptStream(aes, encrypted[:streamNoncePrefixSize+2*segmentSize])
+	if err == nil {
+		t.Fatal("truncated stream should be rejected")
+	}
+	// segments are swapped
+	swapped := bytes.Clone(encrypted)
+	copy(swapped[streamNoncePrefixSize:], encrypted[streamNoncePrefixSize+segmentSize:streamNoncePrefixSize+2*segmentSize])
+	copy(swapped[streamNoncePrefixSize+segmentSize:], encrypted[streamNoncePrefixSize:streamNoncePrefixSize+segmentSize])
+	_, err = decryptStream(aes, swapped)
+	if err == nil {
+		t.Fatal("reordered stream should be rejected")
+	}
+	modified := bytes.Clone(encrypted)
+	modified[len(modified)-1] ^= 1
+	_, err = decryptStream(aes, modified)
+	if err == nil {
+		t.Fatal("modified stream should be rejected")
+	}
+}
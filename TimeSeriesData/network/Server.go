@@ -4,6 +4,8 @@ import (
 	"TimeSeriesData/crypto"
 	"crypto/rsa"
 	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"log"
 	"net"
 	"sync"
@@ -17,14 +19,24 @@ Client message structure (RSA encoded, maximum request data length ~ 462 bytes f
 Server message structure:
 |Response + sha256 of response data encrypted with AES-GCM|
 
+Error response data: |Error message|
+Coded error response data: |Error code - 2 bytes|Error message|
+
 */
 
 const (
-	OK       uint8 = 0
-	OK_BZIP2 uint8 = 1
-	ERROR    uint8 = 0x7F
+	OK         uint8 = 0
+	OK_BZIP2   uint8 = 1
+	ERROR_CODE uint8 = 0x7E
+	ERROR      uint8 = 0x7F
 )
 
+// CodedError is sent to the client as ERROR_CODE response
+type CodedError interface {
+	error
+	ErrorCode() uint16
+}
+
 type TcpServer[T any] struct {
 	port     int
 	key      *rsa.PrivateKey
@@ -32,6 +44,8 @@ type TcpServer[T any] struct {
 	handler  func([]byte, *T) ([]byte, error, bool)
 	userData *T
 	listener *net.TCPListener
+	// called after all requests are handled on server termination
+	shutdownHandler func(*T) error
 }
 
 func NewTcpServer[T any](port int, keyFileName string, label string, userData *T,
@@ -49,6 +63,11 @@ func NewTcpServer[T any](port int, keyFileName string, label string, userData *T
 	}, nil
 }
 
+// SetShutdownHandler sets a handler that is called on server termination, for example to save modified data
+func (s *TcpServer[T]) SetShutdownHandler(handler func(*T) error) {
+	s.shutdownHandler = handler
+}
+
 func (s *TcpServer[T]) Terminate() {
 	l := s.listener
 	s.listener = nil
@@ -72,12 +91,18 @@ func (s *TcpServer[T]) Start() error {
 			log.Printf("Error accepting: %v\nWaiting for all goroutines to finish...\n", err.Error())
 			wg.Wait()
 			s.Terminate()
+			if s.shutdownHandler != nil {
+				shutdownErr := s.shutdownHandler(s.userData)
+				if shutdownErr != nil {
+					log.Printf("shutdown handler error %v\n", shutdownErr.Error())
+				}
+			}
 			log.Println("TCP server terminated")
 			return err
 		}
 		// Handle connections in a new goroutine.
+		wg.Add(1)
 		go func() {
-			wg.Add(1)
 			s.handleTcp(conn)
 			wg.Done()
 		}()
@@ -118,7 +143,11 @@ func (s *TcpServer[T]) handleTcp(conn net.Conn) {
 	}
 	aesKey := decrypted[:32]
 	aesNonce := decrypted[32:44]
-	if err != nil {
+	var codedError CodedError
+	if errors.As(err, &codedError) {
+		data := binary.LittleEndian.AppendUint16(nil, codedError.ErrorCode())
+		sendResponse(conn, aesKey, aesNonce, ERROR_CODE, append(data, codedError.Error()...))
+	} else if err != nil {
 		sendResponse(conn, aesKey, aesNonce, ERROR, []byte(err.Error()))
 	} else if response != nil {
 		compressed, err := bzipData(response)